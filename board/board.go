@@ -5,8 +5,6 @@ import (
 	"fmt"
 	"math/bits"
 	"math/rand"
-	"strconv"
-	"strings"
 )
 
 // --------------------
@@ -24,6 +22,7 @@ const (
 )
 
 type Undo struct {
+	move          moves.Move
 	from, to      int8
 	movingPiece   int8
 	capturedPiece int8
@@ -34,10 +33,12 @@ type Undo struct {
 	bCastleKOld   bool
 	bCastleQOld   bool
 	turnOld       bool
+	halfMovesOld  int
+	hashOld       Bitboard
 }
 
 type BoardMethods interface {
-	FromFen(string)
+	FromFen(string) error
 	GenMoves() []moves.Move
 	SetTurn(bool)
 }
@@ -62,15 +63,23 @@ type Board struct {
 	BCastleQ bool
 	BCastleK bool
 
+	// CastlingRooks are the squares the castling rooks start on, white
+	// kingside, white queenside, black kingside, black queenside. they only
+	// move off the corners in chess960
+	CastlingRooks [4]int8
+	Chess960      bool
+
 	EnPassantTarget int8
 	Mailbox         [64]int8
 
 	AllBitboards [12]*Bitboard
-	UndoStack    [64]Undo
-	UndoCount    int
 
-	Hash            Bitboard
-	PositionHistory map[Bitboard]int
+	// every move played since the position was loaded, search moves included.
+	// entries past UndoCount are spare and get reused
+	UndoStack []Undo
+	UndoCount int
+
+	Hash Bitboard
 
 	HalfMoves int
 	FullMoves int
@@ -165,7 +174,8 @@ func (b *Board) SetTurn(t bool) {
 	b.Turn = t
 }
 
-func (b *Board) FromFen(s string) {
+// linkBitboards points AllBitboards at this board's own bitboards
+func (b *Board) linkBitboards() {
 	b.AllBitboards = [12]*Bitboard{
 		&b.WKings,
 		&b.WQueens,
@@ -181,138 +191,17 @@ func (b *Board) FromFen(s string) {
 		&b.BKnights,
 		&b.BPawns,
 	}
-
-	for i := 0; i < 64; i++ {
-		b.Mailbox[i] = -1
-	}
-
-	b.Hash = CalculateHash(b)
-
-	var posPointer int8 = 0
-	var subdata string
-outerLoop:
-	for xx, ch := range s {
-		switch ch {
-		case '1', '2', '3', '4', '5', '6', '7', '8':
-			num := int8(ch - '0')
-			posPointer += num
-		case 'K':
-			b.WKings.Set(posPointer)
-			b.FilledSquares.Set(posPointer)
-			b.Mailbox[posPointer] = 0
-			posPointer += 1
-		case 'Q':
-			b.WQueens.Set(posPointer)
-			b.FilledSquares.Set(posPointer)
-			b.Mailbox[posPointer] = 1
-			posPointer += 1
-		case 'R':
-			b.WRooks.Set(posPointer)
-			b.FilledSquares.Set(posPointer)
-			b.Mailbox[posPointer] = 2
-			posPointer += 1
-		case 'B':
-			b.WBishops.Set(posPointer)
-			b.FilledSquares.Set(posPointer)
-			b.Mailbox[posPointer] = 3
-			posPointer += 1
-		case 'N':
-			b.WKnights.Set(posPointer)
-			b.FilledSquares.Set(posPointer)
-			b.Mailbox[posPointer] = 4
-			posPointer += 1
-		case 'P':
-			b.WPawns.Set(posPointer)
-			b.FilledSquares.Set(posPointer)
-			b.Mailbox[posPointer] = 5
-			posPointer += 1
-		case 'k':
-			b.BKings.Set(posPointer)
-			b.FilledSquares.Set(posPointer)
-			b.Mailbox[posPointer] = 6
-			posPointer += 1
-		case 'q':
-			b.BQueens.Set(posPointer)
-			b.FilledSquares.Set(posPointer)
-			b.Mailbox[posPointer] = 7
-			posPointer += 1
-		case 'r':
-			b.BRooks.Set(posPointer)
-			b.FilledSquares.Set(posPointer)
-			b.Mailbox[posPointer] = 8
-			posPointer += 1
-		case 'b':
-			b.BBishops.Set(posPointer)
-			b.FilledSquares.Set(posPointer)
-			b.Mailbox[posPointer] = 9
-			posPointer += 1
-		case 'n':
-			b.BKnights.Set(posPointer)
-			b.FilledSquares.Set(posPointer)
-			b.Mailbox[posPointer] = 10
-			posPointer += 1
-		case 'p':
-			b.BPawns.Set(posPointer)
-			b.FilledSquares.Set(posPointer)
-			b.Mailbox[posPointer] = 11
-			posPointer += 1
-
-		case ' ':
-			subdata = s[xx+1 : len(s)]
-			break outerLoop
-		}
-	}
-	//w QKqk e3 41 20
-
-	for i, field := range strings.Fields(subdata) {
-		if field != "-" {
-			for _, runee := range field {
-				switch i + 1 {
-				case 1:
-					if field == "w" {
-						b.Turn = true
-					} else {
-						b.Turn = false
-					}
-				case 2:
-					if runee == 'Q' {
-						b.WCastleQ = true
-					}
-					if runee == 'K' {
-						b.WCastleK = true
-					}
-					if runee == 'q' {
-						b.BCastleQ = true
-					}
-					if runee == 'k' {
-						b.BCastleK = true
-					}
-				case 3:
-					b.EnPassantTarget = notationToSquare(field)
-				case 4:
-					integer, err := strconv.Atoi(field)
-					if err != nil {
-						fmt.Println(err)
-					}
-					b.HalfMoves = integer
-				case 5:
-					integer, err := strconv.Atoi(field)
-					if err != nil {
-						fmt.Println(err)
-					}
-					b.FullMoves = integer
-				}
-			}
-		}
-	}
 }
 
+// Copy returns an independent board, safe to play moves on from another
+// goroutine
 func (b *Board) Copy() *Board {
 	newBoard := &Board{}
 	*newBoard = *b
 
-	// newBoard.pieces = make([]Piece, len(b.pieces))
-	// copy(newBoard.pieces, b.pieces)
+	// the plain copy still points at the old board's bitboards and undo stack
+	newBoard.linkBitboards()
+	newBoard.UndoStack = append([]Undo(nil), b.UndoStack[:b.UndoCount]...)
 
 	return newBoard
 }
@@ -321,6 +210,14 @@ func (b *Board) PieceAt(square int8) int8 {
 	return b.Mailbox[square]
 }
 
+// LastMove is the move that led to this position, 0 if there is none
+func (b *Board) LastMove() moves.Move {
+	if b.UndoCount == 0 {
+		return 0
+	}
+	return b.UndoStack[b.UndoCount-1].move
+}
+
 func (b *Board) DebugPrint() {
 	var finalstr string
 	newlinesadded := 0
@@ -377,14 +274,15 @@ func notationToSquare(notation string) int8 {
 		return -1
 	}
 
-	file := notation[0] - 'a'
-	rank := notation[1] - '1'
+	file := int8(notation[0]) - 'a'
+	rank := int8(notation[1]) - '1'
 
 	if file < 0 || file > 7 || rank < 0 || rank > 7 {
 		return -1
 	}
 
-	return int8(rank)*8 + int8(file)
+	// square 0 is a8, so ranks count down from the top of the board
+	return (7-rank)*8 + file
 }
 
 // --------------------
@@ -446,6 +344,29 @@ func CalculateHash(b *Board) Bitboard {
 		hash ^= ZobristBlackToMove
 	}
 
+	return hash ^ b.stateHash()
+}
+
+// stateHash covers castling rights and the en passant file
+func (b *Board) stateHash() Bitboard {
+	var hash Bitboard
+
+	if b.WCastleK {
+		hash ^= ZobristCastling[0]
+	}
+	if b.WCastleQ {
+		hash ^= ZobristCastling[1]
+	}
+	if b.BCastleK {
+		hash ^= ZobristCastling[2]
+	}
+	if b.BCastleQ {
+		hash ^= ZobristCastling[3]
+	}
+	if b.EnPassantTarget != -1 {
+		hash ^= ZobristEnPassant[b.EnPassantTarget%8]
+	}
+
 	return hash
 }
 
@@ -613,7 +534,10 @@ func (b *Board) Moves(captures bool) moves.MoveList {
 		stillincheck := b.IsKingAttacked()
 		if !stillincheck {
 			if captures {
-				if capture != -1 {
+				// castling is stored king-takes-rook, so an occupied target
+				// alone doesn't make a capture
+				if (capture != -1 && !ourmove.IsCastling()) || ourmove.IsEnPassant() ||
+					ourmove.Flags() == moves.FlagPromotionQueen {
 					filteredmoves.Add(ourmove)
 				}
 			} else {
@@ -625,6 +549,38 @@ func (b *Board) Moves(captures bool) moves.MoveList {
 	return filteredmoves
 }
 
+// pushUndo makes room for one more entry on the undo stack and returns it
+func (b *Board) pushUndo() *Undo {
+	if b.UndoCount == len(b.UndoStack) {
+		b.UndoStack = append(b.UndoStack, Undo{})
+	}
+	b.UndoCount++
+	return &b.UndoStack[b.UndoCount-1]
+}
+
+// TakeBack undoes up to n of the moves played since the position was loaded
+// and returns how many it took back
+func (b *Board) TakeBack(n int) int {
+	taken := 0
+	for ; taken < n && b.UndoCount > 0; taken++ {
+		if move := b.LastMove(); move == 0 {
+			b.UnmakeNullMove()
+		} else {
+			b.UndoMove(move)
+		}
+	}
+	return taken
+}
+
+// History returns the moves played since the position was loaded
+func (b *Board) History() []moves.Move {
+	history := make([]moves.Move, b.UndoCount)
+	for i := range history {
+		history[i] = b.UndoStack[i].move
+	}
+	return history
+}
+
 func (b *Board) PlayMove(move moves.Move) {
 	movingpiece := b.Mailbox[move.From()]
 	if movingpiece == -1 {
@@ -632,7 +588,8 @@ func (b *Board) PlayMove(move moves.Move) {
 	}
 	targetpiece := b.Mailbox[move.To()]
 
-	u := &b.UndoStack[b.UndoCount]
+	u := b.pushUndo()
+	u.move = move
 	u.from = move.From()
 	u.to = move.To()
 	u.movingPiece = movingpiece
@@ -644,7 +601,23 @@ func (b *Board) PlayMove(move moves.Move) {
 	u.bCastleKOld = b.BCastleK
 	u.bCastleQOld = b.BCastleQ
 	u.turnOld = b.Turn
-	b.UndoCount++
+	u.halfMovesOld = b.HalfMoves
+	u.hashOld = b.Hash
+
+	// castling rights and en passant are xored out here and back in once the
+	// move has changed them
+	b.Hash ^= b.stateHash()
+
+	// pawn moves and captures can never be undone over the board, so they
+	// reset the fifty move counter
+	if movingpiece == 5 || movingpiece == 11 || (targetpiece != -1 && !move.IsCastling()) {
+		b.HalfMoves = 0
+	} else {
+		b.HalfMoves++
+	}
+	if !b.Turn {
+		b.FullMoves++
+	}
 
 	b.FilledSquares.Clear(move.From())
 	b.Mailbox[move.From()] = -1
@@ -655,69 +628,25 @@ func (b *Board) PlayMove(move moves.Move) {
 	b.Hash ^= ZobristPieces[movingpiece][u.from]
 
 	if castling {
-		switch move.To() {
-		case 56:
-			//no clear move.froms cuz we do that 6 lines above
-			b.FilledSquares.Clear(move.To())
-			b.FilledSquares.Set(58)
-			b.FilledSquares.Set(59)
-			b.Mailbox[move.To()] = -1
-			b.Mailbox[58] = 0
-			b.Mailbox[59] = 2
-			allbb[0].Clear(move.From()) // white king
-			allbb[0].Set(58)
-			allbb[2].Clear(move.To()) // white rook
-			allbb[2].Set(59)
-
-			b.Hash ^= ZobristPieces[0][58]
-			b.Hash ^= ZobristPieces[2][59]
-			b.Hash ^= ZobristPieces[u.capturedPiece][u.to]
-		case 63:
-			b.FilledSquares.Clear(move.To())
-			b.FilledSquares.Set(61)
-			b.FilledSquares.Set(62)
-			b.Mailbox[move.To()] = -1
-			b.Mailbox[61] = 2
-			b.Mailbox[62] = 0
-			allbb[0].Clear(move.From()) // white king
-			allbb[0].Set(62)
-			allbb[2].Clear(move.To()) // white rook
-			allbb[2].Set(61)
-
-			b.Hash ^= ZobristPieces[0][62]
-			b.Hash ^= ZobristPieces[2][61]
-			b.Hash ^= ZobristPieces[u.capturedPiece][u.to]
-		case 0:
-			b.FilledSquares.Clear(move.To())
-			b.FilledSquares.Set(2)
-			b.FilledSquares.Set(3)
-			b.Mailbox[move.To()] = -1
-			b.Mailbox[2] = 6
-			b.Mailbox[3] = 8
-			allbb[6].Clear(move.From())
-			allbb[6].Set(2)
-			allbb[8].Clear(move.To())
-			allbb[8].Set(3)
-
-			b.Hash ^= ZobristPieces[6][2]
-			b.Hash ^= ZobristPieces[8][3]
-			b.Hash ^= ZobristPieces[u.capturedPiece][u.to]
-		case 7:
-			b.FilledSquares.Clear(move.To())
-			b.FilledSquares.Set(5)
-			b.FilledSquares.Set(6)
-			b.Mailbox[move.To()] = -1
-			b.Mailbox[5] = 8
-			b.Mailbox[6] = 6
-			allbb[6].Clear(move.From())
-			allbb[6].Set(6)
-			allbb[8].Clear(move.To())
-			allbb[8].Set(5)
-
-			b.Hash ^= ZobristPieces[6][6]
-			b.Hash ^= ZobristPieces[8][5]
-			b.Hash ^= ZobristPieces[u.capturedPiece][u.to]
-		}
+		// both pieces are lifted before either is put down, in chess960 the
+		// king can land where the rook stood or the other way round
+		rook := targetpiece
+		kingTo, rookTo := castlingTargets(move.From(), move.To())
+		b.FilledSquares.Clear(move.To())
+		b.Mailbox[move.To()] = -1
+		allbb[movingpiece].Clear(move.From())
+		allbb[rook].Clear(move.To())
+
+		b.FilledSquares.Set(kingTo)
+		b.FilledSquares.Set(rookTo)
+		b.Mailbox[kingTo] = movingpiece
+		b.Mailbox[rookTo] = rook
+		allbb[movingpiece].Set(kingTo)
+		allbb[rook].Set(rookTo)
+
+		b.Hash ^= ZobristPieces[movingpiece][kingTo]
+		b.Hash ^= ZobristPieces[rook][rookTo]
+		b.Hash ^= ZobristPieces[rook][u.to]
 	} else if move.IsPromotion() {
 		b.FilledSquares.Set(move.To())
 
@@ -754,7 +683,7 @@ func (b *Board) PlayMove(move moves.Move) {
 		} else {
 			b.FilledSquares.Clear(move.To() - 8)
 			allbb[5].Clear(move.To() - 8)
-			b.Mailbox[move.To()+8] = -1
+			b.Mailbox[move.To()-8] = -1
 			b.Hash ^= ZobristPieces[5][move.To()-8]
 		}
 	} else if targetpiece > 5 {
@@ -805,22 +734,23 @@ func (b *Board) PlayMove(move moves.Move) {
 		b.BCastleQ = false
 	}
 	if movingpiece == 2 || targetpiece == 2 { //white rook move or takne
-		if move.From() == 0 || move.To() == 0 {
+		if move.From() == b.CastlingRooks[1] || move.To() == b.CastlingRooks[1] {
 			b.WCastleQ = false
 		}
-		if move.From() == 7 || move.To() == 7 {
+		if move.From() == b.CastlingRooks[0] || move.To() == b.CastlingRooks[0] {
 			b.WCastleK = false
 		}
 	}
 	if movingpiece == 8 || targetpiece == 8 { //black rook moved or taken
-		if move.From() == 56 || move.To() == 56 { //it move from square 56 or someone captured square 56
+		if move.From() == b.CastlingRooks[3] || move.To() == b.CastlingRooks[3] { //it moved from the rook square or someone captured it
 			b.BCastleQ = false
 		}
-		if move.From() == 63 || move.To() == 63 {
+		if move.From() == b.CastlingRooks[2] || move.To() == b.CastlingRooks[2] {
 			b.BCastleK = false
 		}
 	}
 
+	b.Hash ^= b.stateHash() ^ ZobristBlackToMove
 	b.Turn = !b.Turn
 }
 
@@ -829,6 +759,12 @@ func (b *Board) UndoMove(move moves.Move) {
 	b.UndoCount--
 	u := &b.UndoStack[b.UndoCount]
 
+	b.HalfMoves = u.halfMovesOld
+	b.Hash = u.hashOld
+	if !u.turnOld {
+		b.FullMoves--
+	}
+
 	allbb := &b.AllBitboards
 
 	b.Turn = u.turnOld
@@ -840,6 +776,25 @@ func (b *Board) UndoMove(move moves.Move) {
 
 	b.EnPassantTarget = u.enPassantOld
 
+	if move.IsCastling() {
+		kingTo, rookTo := castlingTargets(u.from, u.to)
+		rook := u.capturedPiece
+		allbb[u.movingPiece].Clear(kingTo)
+		allbb[rook].Clear(rookTo)
+		b.FilledSquares.Clear(kingTo)
+		b.FilledSquares.Clear(rookTo)
+		b.Mailbox[kingTo] = -1
+		b.Mailbox[rookTo] = -1
+
+		allbb[u.movingPiece].Set(u.from)
+		allbb[rook].Set(u.to)
+		b.FilledSquares.Set(u.from)
+		b.FilledSquares.Set(u.to)
+		b.Mailbox[u.from] = u.movingPiece
+		b.Mailbox[u.to] = rook
+		return
+	}
+
 	if u.promotion != 0 {
 		promotedIndex := u.promotion
 		if !u.turnOld {
@@ -848,30 +803,25 @@ func (b *Board) UndoMove(move moves.Move) {
 
 		allbb[promotedIndex].Clear(u.to)
 		b.Mailbox[u.to] = -1
-		b.Hash ^= ZobristPieces[promotedIndex][u.to]
 
 		allbb[u.movingPiece].Set(u.from)
 		b.Mailbox[u.from] = u.movingPiece
-		b.Hash ^= ZobristPieces[u.movingPiece][u.from]
 	}
 
 	b.FilledSquares.Clear(u.to)
 	b.Mailbox[u.to] = -1
 
 	allbb[u.movingPiece].Clear(u.to)
-	b.Hash ^= ZobristPieces[u.movingPiece][u.to]
 
 	if u.capturedPiece != -1 {
 		allbb[u.capturedPiece].Set(u.to)
 		b.FilledSquares.Set(u.to)
 		b.Mailbox[u.to] = u.capturedPiece
-		b.Hash ^= ZobristPieces[u.capturedPiece][u.to]
 	}
 
 	allbb[u.movingPiece].Set(u.from)
 	b.FilledSquares.Set(u.from)
 	b.Mailbox[u.from] = u.movingPiece
-	b.Hash ^= ZobristPieces[u.movingPiece][u.from]
 
 	// handle en-passant capture
 	if move.IsEnPassant() {
@@ -879,93 +829,47 @@ func (b *Board) UndoMove(move moves.Move) {
 			allbb[11].Set(u.to + 8)
 			b.FilledSquares.Set(u.to + 8)
 			b.Mailbox[u.to+8] = 11
-			b.Hash ^= ZobristPieces[11][u.to+8]
 		} else { // black moved
 			allbb[5].Set(u.to - 8)
 			b.FilledSquares.Set(u.to - 8)
 			b.Mailbox[u.to-8] = 5
-			b.Hash ^= ZobristPieces[5][u.to-8]
 		}
 	}
+}
 
-	if !move.IsCastling() {
-		return
-	}
-	switch move.To() {
-	case 56:
-		allbb[0].Clear(58)
-		allbb[2].Clear(59)
-		allbb[0].Set(60)
-		allbb[2].Set(56)
-		b.FilledSquares.Clear(58)
-		b.FilledSquares.Clear(59)
-		b.FilledSquares.Set(60)
-		b.FilledSquares.Set(56)
-
-		b.Mailbox[58] = -1
-		b.Mailbox[59] = -1
-		b.Mailbox[60] = 0
-		b.Mailbox[56] = 2
-		b.Hash ^= ZobristPieces[0][58]
-		b.Hash ^= ZobristPieces[2][59]
-		b.Hash ^= ZobristPieces[0][60]
-		b.Hash ^= ZobristPieces[2][56]
-	case 63:
-		allbb[0].Clear(62)
-		allbb[2].Clear(61)
-		allbb[0].Set(60)
-		allbb[2].Set(63)
-		b.FilledSquares.Clear(62)
-		b.FilledSquares.Clear(61)
-		b.FilledSquares.Set(60)
-		b.FilledSquares.Set(63)
-
-		b.Mailbox[61] = -1
-		b.Mailbox[62] = -1
-		b.Mailbox[60] = 0
-		b.Mailbox[63] = 2
-		b.Hash ^= ZobristPieces[0][62]
-		b.Hash ^= ZobristPieces[2][61]
-		b.Hash ^= ZobristPieces[0][60]
-		b.Hash ^= ZobristPieces[2][63]
-
-	case 0:
-		allbb[6].Clear(2)
-		allbb[8].Clear(3)
-		allbb[6].Set(4)
-		allbb[8].Set(0)
-		b.FilledSquares.Clear(2)
-		b.FilledSquares.Clear(3)
-		b.FilledSquares.Set(4)
-		b.FilledSquares.Set(0)
-
-		b.Mailbox[2] = -1
-		b.Mailbox[3] = -1
-		b.Mailbox[4] = 6
-		b.Mailbox[0] = 8
-		b.Hash ^= ZobristPieces[6][2]
-		b.Hash ^= ZobristPieces[8][3]
-		b.Hash ^= ZobristPieces[6][4]
-		b.Hash ^= ZobristPieces[8][0]
-
-	case 7:
-		allbb[6].Clear(6)
-		allbb[8].Clear(5)
-		allbb[6].Set(4)
-		allbb[8].Set(7)
-		b.FilledSquares.Clear(6)
-		b.FilledSquares.Clear(5)
-		b.FilledSquares.Set(4)
-		b.FilledSquares.Set(7)
-		b.Mailbox[5] = -1
-		b.Mailbox[6] = -1
-		b.Mailbox[4] = 6
-		b.Mailbox[7] = 8
-		b.Hash ^= ZobristPieces[6][6]
-		b.Hash ^= ZobristPieces[8][5]
-		b.Hash ^= ZobristPieces[6][4]
-		b.Hash ^= ZobristPieces[8][7]
+// MakeNullMove passes the turn without moving, for null move pruning. it goes
+// on the undo stack like a real move so the history stays in step
+func (b *Board) MakeNullMove() {
+	u := b.pushUndo()
+	*u = Undo{
+		enPassantOld: b.EnPassantTarget,
+		wCastleKOld:  b.WCastleK,
+		wCastleQOld:  b.WCastleQ,
+		bCastleKOld:  b.BCastleK,
+		bCastleQOld:  b.BCastleQ,
+		turnOld:      b.Turn,
+		halfMovesOld: b.HalfMoves,
+		hashOld:      b.Hash,
 	}
+
+	b.Hash ^= b.stateHash()
+	b.EnPassantTarget = -1
+	b.Hash ^= b.stateHash() ^ ZobristBlackToMove
+	b.Turn = !b.Turn
+
+	// a position on the far side of a null move is not a real repetition, so
+	// Repetitions must not look past it
+	b.HalfMoves = 0
+}
+
+func (b *Board) UnmakeNullMove() {
+	b.UndoCount--
+	u := &b.UndoStack[b.UndoCount]
+
+	b.Turn = u.turnOld
+	b.EnPassantTarget = u.enPassantOld
+	b.HalfMoves = u.halfMovesOld
+	b.Hash = u.hashOld
 }
 
 func (b *Board) IsInCheck() bool {
@@ -973,6 +877,73 @@ func (b *Board) IsInCheck() bool {
 	return false
 }
 
+// -------------
+// Draw Detection
+// -------------
+
+// Repetitions is how many times the current position has appeared. the undo
+// stack keeps the hash from before every move, and only positions since the
+// last pawn move or capture with the same side to move can match
+func (b *Board) Repetitions() int {
+	count := 1
+	oldest := max(b.UndoCount-b.HalfMoves, 0)
+	for i := b.UndoCount - 2; i >= oldest; i -= 2 {
+		if b.UndoStack[i].hashOld == b.Hash {
+			count++
+		}
+	}
+	return count
+}
+
+func (b *Board) IsThreefoldRepetition() bool {
+	return b.Repetitions() >= 3
+}
+
+func (b *Board) IsFiftyMoveDraw() bool {
+	return b.HalfMoves >= 100
+}
+
+// IsInsufficientMaterial covers the dead positions K v K, KB v K and KN v K
+func (b *Board) IsInsufficientMaterial() bool {
+	if b.WPawns|b.BPawns|b.WRooks|b.BRooks|b.WQueens|b.BQueens != 0 {
+		return false
+	}
+	minors := b.WBishops | b.BBishops | b.WKnights | b.BKnights
+	return bits.OnesCount64(uint64(minors)) <= 1
+}
+
+// IsDraw reports a draw by rule, stalemate is left to the caller since it
+// needs move generation
+func (b *Board) IsDraw() bool {
+	return b.IsThreefoldRepetition() || b.IsFiftyMoveDraw() || b.IsInsufficientMaterial()
+}
+
+// Outcome says whether the game is over and why, result is empty while it is
+// still going
+func (b *Board) Outcome() (result, reason string) {
+	// checkmate ends the game before any draw rule gets a say, even on the
+	// move that makes the fifty
+	noMoves := b.Moves(false).Count == 0
+	if noMoves && b.IsKingAttacked() {
+		if b.Turn {
+			return "0-1", "Black mates"
+		}
+		return "1-0", "White mates"
+	}
+
+	switch {
+	case noMoves:
+		return "1/2-1/2", "Stalemate"
+	case b.IsThreefoldRepetition():
+		return "1/2-1/2", "Draw by repetition"
+	case b.IsFiftyMoveDraw():
+		return "1/2-1/2", "Draw by fifty move rule"
+	case b.IsInsufficientMaterial():
+		return "1/2-1/2", "Insufficient material"
+	}
+	return "", ""
+}
+
 // ---------------
 // Magic Bitboards
 // ---------------
@@ -1572,21 +1543,12 @@ func (b *Board) GenMoves() moves.MoveList {
 			}
 		}
 		//castling
-		if b.WCastleQ {
-			if !(b.FilledSquares.IsSet(57) || b.FilledSquares.IsSet(58) || b.FilledSquares.IsSet(59)) && (b.WKings.IsSet(60) && b.WRooks.IsSet(56)) {
-				if !(b.IsSquareAttacked(57) || b.IsSquareAttacked(58) || b.IsSquareAttacked(59) || b.IsSquareAttacked(60)) {
-					Move := moves.NewMove(60, 56, moves.FlagCastling)
-					allMoves.Add(Move)
-				}
-			}
+		king := int8(bits.TrailingZeros64(uint64(b.WKings)))
+		if b.WCastleQ && b.canCastle(king, b.CastlingRooks[1]) {
+			allMoves.Add(moves.NewMove(king, b.CastlingRooks[1], moves.FlagCastling))
 		}
-		if b.WCastleK {
-			if !(b.FilledSquares.IsSet(61) || b.FilledSquares.IsSet(62)) && (b.WKings.IsSet(60) && b.WRooks.IsSet(63)) {
-				if !(b.IsSquareAttacked(61) || b.IsSquareAttacked(62) || b.IsSquareAttacked(60)) {
-					Move := moves.NewMove(60, 63, moves.FlagCastling)
-					allMoves.Add(Move)
-				}
-			}
+		if b.WCastleK && b.canCastle(king, b.CastlingRooks[0]) {
+			allMoves.Add(moves.NewMove(king, b.CastlingRooks[0], moves.FlagCastling))
 		}
 	} else {
 		for i, bb := range []Bitboard{b.BKings, b.BQueens, b.BRooks, b.BBishops, b.BKnights, b.BPawns} {
@@ -1696,21 +1658,12 @@ func (b *Board) GenMoves() moves.MoveList {
 			}
 		}
 		//castling
-		if b.BCastleQ {
-			if !(b.FilledSquares.IsSet(1) || b.FilledSquares.IsSet(2) || b.FilledSquares.IsSet(3)) && (b.BKings.IsSet(4) && b.BRooks.IsSet(0)) {
-				if !(b.IsSquareAttacked(1) || b.IsSquareAttacked(2) || b.IsSquareAttacked(3) || b.IsSquareAttacked(4)) {
-					Move := moves.NewMove(4, 0, moves.FlagCastling)
-					allMoves.Add(Move)
-				}
-			}
+		king := int8(bits.TrailingZeros64(uint64(b.BKings)))
+		if b.BCastleQ && b.canCastle(king, b.CastlingRooks[3]) {
+			allMoves.Add(moves.NewMove(king, b.CastlingRooks[3], moves.FlagCastling))
 		}
-		if b.BCastleK {
-			if !(b.FilledSquares.IsSet(5) || b.FilledSquares.IsSet(6)) && (b.BKings.IsSet(4) && b.BRooks.IsSet(7)) {
-				if !(b.IsSquareAttacked(5) || b.IsSquareAttacked(6) || b.IsSquareAttacked(4)) {
-					Move := moves.NewMove(4, 7, moves.FlagCastling)
-					allMoves.Add(Move)
-				}
-			}
+		if b.BCastleK && b.canCastle(king, b.CastlingRooks[2]) {
+			allMoves.Add(moves.NewMove(king, b.CastlingRooks[2], moves.FlagCastling))
 		}
 	}
 
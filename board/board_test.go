@@ -0,0 +1,86 @@
+package board
+
+import "testing"
+
+func TestNullMove(t *testing.T) {
+	for _, fen := range []string{
+		StartFen,
+		"rnbqkbnr/pp1ppppp/8/2p5/4P3/8/PPPP1PPP/RNBQKBNR w KQkq c6 0 2",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R b KQkq - 3 1",
+		"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9",
+	} {
+		b := mustFen(t, fen)
+		hash, want := b.Hash, b.ToFen()
+
+		b.MakeNullMove()
+		if b.Turn == mustFen(t, fen).Turn || b.EnPassantTarget != -1 {
+			t.Errorf("%s: null move left turn %v, en passant %d", fen, b.Turn, b.EnPassantTarget)
+		}
+		if b.Hash != CalculateHash(b) {
+			t.Errorf("%s: hash after a null move doesn't match a full calculation", fen)
+		}
+		if b.LastMove() != 0 || b.Repetitions() != 1 {
+			t.Errorf("%s: last move %v, %d repetitions", fen, b.LastMove(), b.Repetitions())
+		}
+
+		// the other side can play on from there and take its moves back
+		legal := b.Moves(false)
+		for i := 0; i < legal.Count; i++ {
+			b.PlayMove(legal.Moves[i])
+			if b.Hash != CalculateHash(b) {
+				t.Errorf("%s: hash wrong after null move and %s", fen, legal.Moves[i].MoveToString())
+			}
+			b.UndoMove(legal.Moves[i])
+		}
+
+		b.UnmakeNullMove()
+		if b.Hash != hash || b.ToFen() != want || b.UndoCount != 0 {
+			t.Errorf("%s: unmake gave %s", fen, b.ToFen())
+		}
+	}
+}
+
+func TestTakeBack(t *testing.T) {
+	afterE4 := "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1"
+	for _, c := range []struct {
+		shuffles, n, taken int
+		fen                string
+	}{
+		{0, 1, 1, StartFen},
+		{0, 5, 1, StartFen},
+		{1, 4, 4, afterE4},
+		{1, 2, 2, "rnbqkb1r/pppppppp/5n2/8/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 2 2"},
+
+		// far more than the old fixed stack of 64 could hold
+		{50, 200, 200, afterE4},
+		{50, 199, 199, "rnbqkb1r/pppppppp/5n2/8/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 1 2"},
+		{50, 1000, 201, StartFen},
+	} {
+		b := mustFen(t, StartFen)
+		play(t, b, "e2e4")
+		for i := 0; i < c.shuffles; i++ {
+			play(t, b, "g8f6", "g1f3", "f6g8", "f3g1")
+		}
+		if got := len(b.History()); got != 1+4*c.shuffles {
+			t.Fatalf("history has %d moves, want %d", got, 1+4*c.shuffles)
+		}
+
+		if taken := b.TakeBack(c.n); taken != c.taken {
+			t.Errorf("%d shuffles: TakeBack(%d) = %d, want %d", c.shuffles, c.n, taken, c.taken)
+		}
+		if fen := b.ToFen(); fen != c.fen || b.Hash != mustFen(t, c.fen).Hash {
+			t.Errorf("%d shuffles: TakeBack(%d) left %s, want %s", c.shuffles, c.n, fen, c.fen)
+		}
+	}
+}
+
+// a null move on the stack is taken back like any other
+func TestTakeBackNullMove(t *testing.T) {
+	b := mustFen(t, StartFen)
+	play(t, b, "e2e4")
+	b.MakeNullMove()
+	play(t, b, "d2d4")
+	if taken := b.TakeBack(3); taken != 3 || b.ToFen() != StartFen {
+		t.Errorf("TakeBack(3) = %d, left %s", taken, b.ToFen())
+	}
+}
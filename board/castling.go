@@ -0,0 +1,73 @@
+package board
+
+// castling is stored king-takes-rook, which works the same for chess960 where
+// the king and rooks can start on any file of the back rank. wherever they
+// start, they end up where they would in normal chess: king on g or c, rook
+// on f or d
+
+// castlingTargets returns where the king and rook land for the castling move
+// king-takes-rook
+func castlingTargets(king, rook int8) (kingTo, rookTo int8) {
+	rank := king / 8 * 8
+	if rook > king {
+		return rank + 6, rank + 5
+	}
+	return rank + 2, rank + 3
+}
+
+// canCastle checks castling with the rook on rook is possible for the side to
+// move, apart from the king being in check where it lands which Moves catches
+// like for any other king move. every square the king or rook crosses or
+// lands on must be empty, not counting the two of them, and none of the
+// squares the king passes through may be attacked
+func (b *Board) canCastle(king, rook int8) bool {
+	rooks := b.WRooks
+	if !b.Turn {
+		rooks = b.BRooks
+	}
+	if !rooks.IsSet(rook) {
+		return false
+	}
+
+	kingTo, rookTo := castlingTargets(king, rook)
+	occ := b.FilledSquares &^ (Bitboard(1)<<king | Bitboard(1)<<rook)
+	for sq := min(king, rook, kingTo, rookTo); sq <= max(king, rook, kingTo, rookTo); sq++ {
+		if occ.IsSet(sq) {
+			return false
+		}
+	}
+
+	step := int8(1)
+	if kingTo < king {
+		step = -1
+	}
+	for sq := king; ; sq += step {
+		if b.IsSquareAttacked(int(sq)) {
+			return false
+		}
+		if sq == kingTo {
+			return true
+		}
+	}
+}
+
+// outerRook finds the rook furthest from the king on the given side of it, the
+// one K and Q mean in X-FEN. -1 if there is none
+func (b *Board) outerRook(king int8, kingside bool) int8 {
+	rook := b.Mailbox[king] + 2
+	rank := king / 8 * 8
+	if kingside {
+		for sq := rank + 7; sq > king; sq-- {
+			if b.Mailbox[sq] == rook {
+				return sq
+			}
+		}
+	} else {
+		for sq := rank; sq < king; sq++ {
+			if b.Mailbox[sq] == rook {
+				return sq
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,113 @@
+package board
+
+import (
+	"strings"
+	"testing"
+)
+
+// play makes the moves on b, given as coordinate moves
+func play(t *testing.T, b *Board, moves ...string) {
+	t.Helper()
+	for _, s := range moves {
+		move, err := b.ParseMove(s)
+		if err != nil {
+			t.Fatalf("%s: %v", b.ToFen(), err)
+		}
+		b.PlayMove(move)
+	}
+}
+
+func TestRepetitions(t *testing.T) {
+	b := mustFen(t, StartFen)
+	shuffle := []string{"g1f3", "g8f6", "f3g1", "f6g8"}
+
+	for want := 2; want <= 3; want++ {
+		play(t, b, shuffle...)
+		if got := b.Repetitions(); got != want {
+			t.Fatalf("after %d shuffles: %d repetitions, want %d", want-1, got, want)
+		}
+	}
+	if !b.IsThreefoldRepetition() || !b.IsDraw() {
+		t.Errorf("third repetition is not a draw")
+	}
+
+	// after a pawn move nothing before it can come back
+	play(t, b, "e2e3", "g8f6", "g1f3", "f6g8", "f3g1")
+	if got := b.Repetitions(); got != 2 {
+		t.Errorf("repetitions after a pawn move = %d, want 2", got)
+	}
+}
+
+func TestFiftyMoves(t *testing.T) {
+	b := mustFen(t, "4k3/8/8/8/8/8/4P3/R3K3 w - - 98 80")
+	play(t, b, "a1a2")
+	if b.IsFiftyMoveDraw() {
+		t.Fatalf("draw after 99 half moves")
+	}
+	play(t, b, "e8d8")
+	if !b.IsFiftyMoveDraw() || !b.IsDraw() {
+		t.Errorf("no draw after 100 half moves")
+	}
+
+	// pawn moves and captures reset the count
+	b = mustFen(t, "4k3/8/8/8/8/8/4P3/R3K3 w - - 99 80")
+	play(t, b, "e2e4")
+	if b.HalfMoves != 0 {
+		t.Errorf("pawn move left the clock at %d", b.HalfMoves)
+	}
+	b = mustFen(t, "r3k3/8/8/8/8/8/8/R3K3 w - - 99 80")
+	play(t, b, "a1a8")
+	if b.HalfMoves != 0 {
+		t.Errorf("capture left the clock at %d", b.HalfMoves)
+	}
+	b.UndoMove(b.LastMove())
+	if b.HalfMoves != 99 {
+		t.Errorf("undo restored the clock to %d", b.HalfMoves)
+	}
+}
+
+func TestInsufficientMaterial(t *testing.T) {
+	for _, c := range []struct {
+		fen  string
+		dead bool
+	}{
+		{"4k3/8/8/8/8/8/8/4K3 w - - 0 1", true},
+		{"4k3/8/8/8/8/8/8/2B1K3 w - - 0 1", true},
+		{"4k3/8/8/8/8/8/8/1N2K3 w - - 0 1", true},
+		{"1n2k3/8/8/8/8/8/8/4K3 w - - 0 1", true},
+		{"4k3/8/8/8/8/8/8/1NN1K3 w - - 0 1", false},
+		{"2b1k3/8/8/8/8/8/8/2B1K3 w - - 0 1", false},
+		{"4k3/8/8/8/8/8/4P3/4K3 w - - 0 1", false},
+		{"4k3/8/8/8/8/8/8/R3K3 w - - 0 1", false},
+		{"3qk3/8/8/8/8/8/8/4K3 w - - 0 1", false},
+	} {
+		b := mustFen(t, c.fen)
+		if got := b.IsInsufficientMaterial(); got != c.dead {
+			t.Errorf("%s: IsInsufficientMaterial = %v", c.fen, got)
+		}
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	for _, c := range []struct {
+		fen, moves     string
+		result, reason string
+	}{
+		{StartFen, "e2e4", "", ""},
+		{"rnbqkbnr/pppp1ppp/8/4p3/6P1/5P2/PPPPP2P/RNBQKBNR b KQkq - 0 2", "d8h4", "0-1", "Black mates"},
+		{"7k/8/6K1/8/8/8/8/R7 w - - 80 80", "a1a8", "1-0", "White mates"},
+		{"7k/8/6K1/8/8/8/8/5Q2 w - - 0 1", "f1f7", "1/2-1/2", "Stalemate"},
+		{"4k3/8/8/8/8/8/3p4/4K3 w - - 0 1", "e1d2", "1/2-1/2", "Insufficient material"},
+		{"7k/8/6K1/8/8/8/8/R7 w - - 98 80", "g6f6 h8h7", "1/2-1/2", "Draw by fifty move rule"},
+
+		// mate on the move that reaches a hundred half moves still wins
+		{"7k/8/6K1/8/8/8/8/R7 w - - 99 80", "a1a8", "1-0", "White mates"},
+	} {
+		b := mustFen(t, c.fen)
+		play(t, b, strings.Fields(c.moves)...)
+		result, reason := b.Outcome()
+		if result != c.result || reason != c.reason {
+			t.Errorf("%s %s: Outcome = %q %q, want %q %q", c.fen, c.moves, result, reason, c.result, c.reason)
+		}
+	}
+}
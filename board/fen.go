@@ -0,0 +1,317 @@
+package board
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+const fenPieces = "KQRBNPkqrbnp"
+
+const StartFen = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+// FromFen loads a position from FEN. every field is checked and so is the
+// position itself, on any error the board is left as it was. the two move
+// counters may be left out, they default to 0 and 1
+func (b *Board) FromFen(s string) error {
+	fields := strings.Fields(s)
+	if len(fields) < 4 || len(fields) > 6 {
+		return fmt.Errorf("fen: expected 4 to 6 fields, got %d", len(fields))
+	}
+
+	var nb Board
+	nb.EnPassantTarget = -1
+	nb.FullMoves = 1
+	nb.linkBitboards()
+	for i := range nb.Mailbox {
+		nb.Mailbox[i] = -1
+	}
+
+	if err := nb.parsePlacement(fields[0]); err != nil {
+		return err
+	}
+
+	switch fields[1] {
+	case "w":
+		nb.Turn = true
+	case "b":
+		nb.Turn = false
+	default:
+		return fmt.Errorf("fen: side to move must be w or b, got %q", fields[1])
+	}
+
+	if err := nb.parseCastling(fields[2]); err != nil {
+		return err
+	}
+	if err := nb.parseEnPassant(fields[3]); err != nil {
+		return err
+	}
+
+	if len(fields) > 4 {
+		n, err := strconv.Atoi(fields[4])
+		if err != nil || n < 0 {
+			return fmt.Errorf("fen: invalid halfmove clock %q", fields[4])
+		}
+		nb.HalfMoves = n
+	}
+	if len(fields) > 5 {
+		n, err := strconv.Atoi(fields[5])
+		if err != nil || n < 1 {
+			return fmt.Errorf("fen: invalid fullmove number %q", fields[5])
+		}
+		nb.FullMoves = n
+	}
+
+	if err := nb.validate(); err != nil {
+		return err
+	}
+
+	nb.Hash = CalculateHash(&nb)
+	*b = nb
+	b.linkBitboards()
+	return nil
+}
+
+func (b *Board) parsePlacement(placement string) error {
+	ranks := strings.Split(placement, "/")
+	if len(ranks) != 8 {
+		return fmt.Errorf("fen: expected 8 ranks, got %d", len(ranks))
+	}
+
+	for r, rank := range ranks {
+		file := 0
+		lastDigit := false
+		for _, ch := range rank {
+			switch {
+			case ch >= '1' && ch <= '8':
+				if lastDigit {
+					return fmt.Errorf("fen: consecutive digits on rank %d", 8-r)
+				}
+				file += int(ch - '0')
+				lastDigit = true
+			case strings.ContainsRune(fenPieces, ch):
+				if file >= 8 {
+					return fmt.Errorf("fen: rank %d has more than 8 squares", 8-r)
+				}
+				square := int8(r*8 + file)
+				piece := int8(strings.IndexRune(fenPieces, ch))
+				b.AllBitboards[piece].Set(square)
+				b.FilledSquares.Set(square)
+				b.Mailbox[square] = piece
+				file++
+				lastDigit = false
+			default:
+				return fmt.Errorf("fen: invalid piece %q on rank %d", ch, 8-r)
+			}
+		}
+		if file != 8 {
+			return fmt.Errorf("fen: rank %d has %d squares, expected 8", 8-r, file)
+		}
+	}
+	return nil
+}
+
+// the castling rooks of a normal game, in CastlingRooks order
+var standardCastlingRooks = [4]int8{63, 56, 7, 0}
+
+// parseCastling reads KQkq, X-FEN (KQkq meaning the outermost rook, a file
+// letter for any other) and Shredder-FEN (file letters only, HAha). it runs
+// after the placement so the rooks can be looked up. any right that isn't
+// the e-file king and a corner rook makes the game chess960
+func (b *Board) parseCastling(castling string) error {
+	b.CastlingRooks = standardCastlingRooks
+	if castling == "-" {
+		return nil
+	}
+
+	for _, ch := range castling {
+		white := ch >= 'A' && ch <= 'Z'
+		colour, rank, kingPiece, rookPiece := "white", int8(56), int8(0), int8(2)
+		if !white {
+			colour, rank, kingPiece, rookPiece = "black", 0, 6, 8
+		}
+
+		king := int8(-1)
+		for sq := rank; sq < rank+8; sq++ {
+			if b.Mailbox[sq] == kingPiece {
+				king = sq
+			}
+		}
+
+		rook := int8(-1)
+		switch lower := ch | 0x20; {
+		case lower == 'k' || lower == 'q':
+			if king != -1 {
+				rook = b.outerRook(king, lower == 'k')
+			}
+		case lower >= 'a' && lower <= 'h':
+			if sq := rank + int8(lower-'a'); b.Mailbox[sq] == rookPiece {
+				rook = sq
+			}
+		default:
+			return fmt.Errorf("fen: invalid castling right %q", ch)
+		}
+
+		if king == -1 {
+			return fmt.Errorf("fen: %s can castle but its king is not on its back rank", colour)
+		}
+		if rook == -1 {
+			return fmt.Errorf("fen: castling right %q has no rook to castle with", ch)
+		}
+
+		i := 0
+		if rook < king {
+			i = 1
+		}
+		if !white {
+			i += 2
+		}
+		right := []*bool{&b.WCastleK, &b.WCastleQ, &b.BCastleK, &b.BCastleQ}[i]
+		if *right {
+			return fmt.Errorf("fen: castling right %q given twice", ch)
+		}
+		*right = true
+		b.CastlingRooks[i] = rook
+
+		if king%8 != 4 || rook != standardCastlingRooks[i] {
+			b.Chess960 = true
+		}
+	}
+	return nil
+}
+
+func (b *Board) parseEnPassant(field string) error {
+	if field == "-" {
+		return nil
+	}
+
+	square := notationToSquare(field)
+	if square == -1 {
+		return fmt.Errorf("fen: invalid en passant square %q", field)
+	}
+	b.EnPassantTarget = square
+	return nil
+}
+
+// validate rejects positions that can't come up in a game
+func (b *Board) validate() error {
+	count := func(bb Bitboard) int { return bits.OnesCount64(uint64(bb)) }
+
+	if n := count(b.WKings); n != 1 {
+		return fmt.Errorf("fen: white has %d kings", n)
+	}
+	if n := count(b.BKings); n != 1 {
+		return fmt.Errorf("fen: black has %d kings", n)
+	}
+
+	white := b.WKings | b.WQueens | b.WRooks | b.WBishops | b.WKnights | b.WPawns
+	if count(white) > 16 || count(b.WPawns) > 8 {
+		return fmt.Errorf("fen: white has too many pieces")
+	}
+	if count(b.FilledSquares&^white) > 16 || count(b.BPawns) > 8 {
+		return fmt.Errorf("fen: black has too many pieces")
+	}
+
+	// rank 8 is squares 0-7, rank 1 is 56-63
+	const backRanks Bitboard = 0xFF000000000000FF
+	if (b.WPawns|b.BPawns)&backRanks != 0 {
+		return fmt.Errorf("fen: pawn on the first or last rank")
+	}
+
+	if ep := b.EnPassantTarget; ep != -1 {
+		// the pawn that just moved two squares sits in front of the target
+		// and both squares it passed over are empty
+		rank, pawnSquare, startSquare, pawn := int8(6), ep+8, ep-8, int8(11)
+		if !b.Turn {
+			rank, pawnSquare, startSquare, pawn = 3, ep-8, ep+8, 5
+		}
+		if 8-ep/8 != rank {
+			return fmt.Errorf("fen: en passant square %s is on the wrong rank", squareToNotation(ep))
+		}
+		if b.Mailbox[pawnSquare] != pawn || b.Mailbox[ep] != -1 || b.Mailbox[startSquare] != -1 {
+			return fmt.Errorf("fen: no pawn can have just moved past %s", squareToNotation(ep))
+		}
+	}
+
+	// the side that just moved can't have left its king in check
+	b.Turn = !b.Turn
+	check := b.IsKingAttacked()
+	b.Turn = !b.Turn
+	if check {
+		return fmt.Errorf("fen: the side not to move is in check")
+	}
+
+	return nil
+}
+
+// ToFen writes the position as a FEN string
+func (b *Board) ToFen() string {
+	var sb strings.Builder
+
+	for rank := 0; rank < 8; rank++ {
+		empty := 0
+		for file := 0; file < 8; file++ {
+			piece := b.Mailbox[rank*8+file]
+			if piece == -1 {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteByte(byte('0' + empty))
+				empty = 0
+			}
+			sb.WriteByte(fenPieces[piece])
+		}
+		if empty > 0 {
+			sb.WriteByte(byte('0' + empty))
+		}
+		if rank < 7 {
+			sb.WriteByte('/')
+		}
+	}
+
+	if b.Turn {
+		sb.WriteString(" w ")
+	} else {
+		sb.WriteString(" b ")
+	}
+
+	// X-FEN, so a normal game comes out as plain KQkq
+	castling := ""
+	for i, right := range []bool{b.WCastleK, b.WCastleQ, b.BCastleK, b.BCastleQ} {
+		if !right {
+			continue
+		}
+		rook := b.CastlingRooks[i]
+		king := int8(bits.TrailingZeros64(uint64(b.WKings)))
+		if i >= 2 {
+			king = int8(bits.TrailingZeros64(uint64(b.BKings)))
+		}
+
+		letter := "KQkq"[i]
+		if b.outerRook(king, i%2 == 0) != rook {
+			letter = byte('A' + rook%8)
+			if i >= 2 {
+				letter += 'a' - 'A'
+			}
+		}
+		castling += string(letter)
+	}
+	if castling == "" {
+		castling = "-"
+	}
+	sb.WriteString(castling)
+
+	ep := "-"
+	if b.EnPassantTarget != -1 {
+		ep = squareToNotation(b.EnPassantTarget)
+	}
+	fmt.Fprintf(&sb, " %s %d %d", ep, b.HalfMoves, b.FullMoves)
+
+	return sb.String()
+}
+
+func squareToNotation(square int8) string {
+	return fmt.Sprintf("%c%c", 'a'+square%8, '8'-square/8)
+}
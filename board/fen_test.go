@@ -0,0 +1,96 @@
+package board
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFromFenErrors(t *testing.T) {
+	for _, c := range []struct {
+		fen, err string
+	}{
+		{"8/8/8/8/8/8/8/8 w", "expected 4 to 6 fields"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1 extra", "expected 4 to 6 fields"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR x KQkq - 0 1", "side to move"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - -1 1", "halfmove clock"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 0", "fullmove number"},
+		{"rnbqkbnr/pppppppp/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "expected 8 ranks"},
+		{"rnbqkbnr/pppppppp/44/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "consecutive digits"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNRR w KQkq - 0 1", "more than 8 squares"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNX w KQkq - 0 1", "invalid piece"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBN w KQkq - 0 1", "has 7 squares"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkx - 0 1", "invalid castling right"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQ1BNR w K - 0 1", "king is not on its back rank"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBN1 w K - 0 1", "no rook to castle with"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KH - 0 1", "given twice"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq e9 0 1", "invalid en passant square"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq e4 0 1", "wrong rank"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq e6 0 1", "no pawn can have just moved"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQQBNR w kq - 0 1", "white has 0 kings"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBKKBNR w kq - 0 1", "white has 2 kings"},
+		{"rnbqqbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQ - 0 1", "black has 0 kings"},
+		{"rnbqkbnr/pppppppp/8/8/8/P7/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "white has too many pieces"},
+		{"rnbqkbnr/pppppppp/p7/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "black has too many pieces"},
+		{"rnbqkbnP/pppppppp/8/8/8/8/1PPPPPPP/RNBQKBNR w KQq - 0 1", "pawn on the first or last rank"},
+		{"rnbqkbnr/pppp1ppp/8/8/8/8/PPPPQPPP/RNB1KBNR w KQkq - 0 1", "side not to move is in check"},
+	} {
+		var b Board
+		b.FromFen(StartFen)
+		err := b.FromFen(c.fen)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: got %v, want %q", c.fen, err, c.err)
+			continue
+		}
+		if b.ToFen() != StartFen {
+			t.Errorf("%s: board changed after an error", c.fen)
+		}
+	}
+}
+
+func TestFenRoundTrip(t *testing.T) {
+	for _, c := range []struct {
+		in       string
+		out      string // what ToFen writes, in when empty
+		chess960 bool
+	}{
+		// standard
+		{StartFen, "", false},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", "", false},
+		{"rnbqkbnr/pp1ppppp/8/2p5/4P3/8/PPPP1PPP/RNBQKBNR w KQkq c6 0 2", "", false},
+		{"4k3/8/8/8/8/8/8/4K3 b - - 57 103", "", false},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w Kq - 3 20", "", false},
+
+		// X-FEN, letters only where the rook isn't the outermost one
+		{"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w KQkq - 2 9", "", true},
+		{"qbbnnrkr/2pp2pp/p7/1p2pp2/8/P3PP2/1PPP1KPP/QBBNNR1R w kq - 0 9", "", true},
+		{"rr2k3/8/8/8/8/8/8/RR2K3 w Bb - 0 1", "", true},
+
+		// Shredder-FEN comes back as X-FEN
+		{"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9",
+			"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w KQkq - 2 9", true},
+		{"b1q1rrkb/pppppppp/3nn3/8/P7/1PPP4/4PPPP/BQNNRKRB w GE - 1 9",
+			"b1q1rrkb/pppppppp/3nn3/8/P7/1PPP4/4PPPP/BQNNRKRB w KQ - 1 9", true},
+		{"rr2k3/8/8/8/8/8/8/RR2K3 w Ab - 0 1",
+			"rr2k3/8/8/8/8/8/8/RR2K3 w Qb - 0 1", true},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w HAha - 0 1", StartFen, false},
+	} {
+		want := c.out
+		if want == "" {
+			want = c.in
+		}
+
+		b := mustFen(t, c.in)
+		if got := b.ToFen(); got != want {
+			t.Errorf("ToFen(FromFen(%s)) = %s, want %s", c.in, got, want)
+		}
+		if b.Chess960 != c.chess960 {
+			t.Errorf("%s: Chess960 = %v", c.in, b.Chess960)
+		}
+
+		// the written fen has to load back to the very same position
+		again := mustFen(t, want)
+		if again.Hash != b.Hash || again.CastlingRooks != b.CastlingRooks {
+			t.Errorf("%s: reloading %s gives a different position", c.in, want)
+		}
+	}
+}
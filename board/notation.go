@@ -0,0 +1,189 @@
+package board
+
+import (
+	"bot/moves"
+	"fmt"
+	"strings"
+)
+
+// ParseMove finds the legal move matching a coordinate move string such as
+// e2e4 or e7e8q. castling is accepted both as the king's destination (e1g1)
+// and as king-takes-rook (e1h1), except in chess960 where the king can
+// already be next to its destination and only king-takes-rook is clear
+func (b *Board) ParseMove(s string) (moves.Move, error) {
+	s = strings.ToLower(s)
+	legal := b.Moves(false)
+	for i := 0; i < legal.Count; i++ {
+		move := legal.Moves[i]
+		if b.MoveToUCI(move) == s || move.MoveToString() == s {
+			return move, nil
+		}
+	}
+	return 0, fmt.Errorf("illegal move %s", s)
+}
+
+// MoveToUCI writes move the way a UCI gui expects it, which for castling
+// depends on whether the game is chess960
+func (b *Board) MoveToUCI(move moves.Move) string {
+	if b.Chess960 {
+		return move.MoveToString()
+	}
+	return move.MoveToUCI()
+}
+
+const sanPieces = "KQRBN"
+
+// MoveToSAN writes a legal move in standard algebraic notation (Nf3, exd5,
+// e8=Q, O-O-O, Qh4#), disambiguating by file, then rank, then both
+func (b *Board) MoveToSAN(move moves.Move) string {
+	var san string
+	from, to := move.From(), move.To()
+
+	if move.IsCastling() {
+		san = "O-O"
+		if to < from {
+			san = "O-O-O"
+		}
+	} else {
+		piece := b.Mailbox[from] % 6
+		capture := b.Mailbox[to] != -1 || move.IsEnPassant()
+
+		if piece == 5 {
+			if capture {
+				san = squareToNotation(from)[:1] + "x"
+			}
+		} else {
+			san = sanPieces[piece : piece+1]
+
+			// look for another piece of the same kind that can reach to
+			sameFile, sameRank, ambiguous := false, false, false
+			legal := b.Moves(false)
+			for i := 0; i < legal.Count; i++ {
+				other := legal.Moves[i]
+				if other == move || other.To() != to || other.IsCastling() || b.Mailbox[other.From()]%6 != piece {
+					continue
+				}
+				ambiguous = true
+				sameFile = sameFile || other.From()%8 == from%8
+				sameRank = sameRank || other.From()/8 == from/8
+			}
+
+			square := squareToNotation(from)
+			switch {
+			case !ambiguous:
+			case !sameFile:
+				san += square[:1]
+			case !sameRank:
+				san += square[1:]
+			default:
+				san += square
+			}
+
+			if capture {
+				san += "x"
+			}
+		}
+
+		san += squareToNotation(to)
+		if move.IsPromotion() {
+			san += "=" + sanPieces[move.PromotionPiece():move.PromotionPiece()+1]
+		}
+	}
+
+	b.PlayMove(move)
+	if b.IsKingAttacked() {
+		if b.Moves(false).Count == 0 {
+			san += "#"
+		} else {
+			san += "+"
+		}
+	}
+	b.UndoMove(move)
+
+	return san
+}
+
+// ParseSAN finds the legal move written in standard algebraic notation. it
+// is lenient about check marks, annotations, a missing capture sign or "="
+// and disambiguation that wasn't needed, but a move that fits more than one
+// legal move is an error
+func (b *Board) ParseSAN(s string) (moves.Move, error) {
+	san := strings.TrimRight(s, "+#!?")
+	legal := b.Moves(false)
+
+	switch san {
+	case "O-O", "0-0", "O-O-O", "0-0-0":
+		queenside := len(san) == 5
+		for i := 0; i < legal.Count; i++ {
+			move := legal.Moves[i]
+			if move.IsCastling() && (move.To() < move.From()) == queenside {
+				return move, nil
+			}
+		}
+		return 0, fmt.Errorf("illegal move %s", s)
+	}
+
+	// promotion piece on the end, with or without the "="
+	promotion := -1
+	if n := len(san); n > 2 && strings.IndexByte(sanPieces[1:], san[n-1]) != -1 {
+		promotion = strings.IndexByte(sanPieces, san[n-1])
+		san = strings.TrimSuffix(san[:n-1], "=")
+	}
+
+	if len(san) < 2 {
+		return 0, fmt.Errorf("invalid move %s", s)
+	}
+	to := notationToSquare(san[len(san)-2:])
+	if to == -1 {
+		return 0, fmt.Errorf("invalid move %s", s)
+	}
+	san = san[:len(san)-2]
+
+	piece := 5
+	if len(san) > 0 && strings.IndexByte(sanPieces, san[0]) != -1 {
+		piece = strings.IndexByte(sanPieces, san[0])
+		san = san[1:]
+	}
+	san = strings.TrimSuffix(san, "x")
+
+	// whatever is left narrows down the from square
+	fromFile, fromRank := int8(-1), int8(-1)
+	for _, ch := range san {
+		switch {
+		case ch >= 'a' && ch <= 'h':
+			fromFile = int8(ch - 'a')
+		case ch >= '1' && ch <= '8':
+			fromRank = int8('8' - ch)
+		default:
+			return 0, fmt.Errorf("invalid move %s", s)
+		}
+	}
+
+	var found moves.Move
+	matches := 0
+	for i := 0; i < legal.Count; i++ {
+		move := legal.Moves[i]
+		from := move.From()
+		switch {
+		case move.IsCastling(), move.To() != to, int(b.Mailbox[from]%6) != piece:
+			continue
+		case fromFile != -1 && from%8 != fromFile, fromRank != -1 && from/8 != fromRank:
+			continue
+		case move.IsPromotion() != (promotion != -1):
+			continue
+		case move.IsPromotion() && int(move.PromotionPiece()) != promotion:
+			continue
+		}
+		found = move
+		matches++
+	}
+
+	switch matches {
+	case 0:
+		return 0, fmt.Errorf("illegal move %s", s)
+	case 1:
+		return found, nil
+	default:
+		return 0, fmt.Errorf("ambiguous move %s", s)
+	}
+}
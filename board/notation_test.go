@@ -0,0 +1,104 @@
+package board
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSAN(t *testing.T) {
+	for _, c := range []struct {
+		fen, move, san string
+	}{
+		{StartFen, "g1f3", "Nf3"},
+		{StartFen, "e2e4", "e4"},
+
+		// disambiguation by file, by rank, and by both when neither is enough
+		{"4k3/8/8/8/8/8/8/R4RK1 w - - 0 1", "a1d1", "Rad1"},
+		{"4k3/8/8/8/8/8/8/R4RK1 w - - 0 1", "f1d1", "Rfd1"},
+		{"4k3/8/8/R7/8/8/8/R3K3 w - - 0 1", "a1a3", "R1a3"},
+		{"4k3/8/8/R7/8/8/8/R3K3 w - - 0 1", "a5a3", "R5a3"},
+		{"8/8/1k6/8/4Q2Q/8/8/K6Q w - - 0 1", "h4e1", "Qh4e1"},
+		{"8/8/1k6/8/4Q2Q/8/8/K6Q w - - 0 1", "e4e1", "Qee1"},
+		{"8/8/1k6/8/4Q2Q/8/8/K6Q w - - 0 1", "h1e1", "Q1e1"},
+
+		// captures, en passant and promotion
+		{"rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 2", "e4d5", "exd5"},
+		{"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3", "e5f6", "exf6"},
+		{"3r3k/4P3/8/8/8/8/8/4K3 w - - 0 1", "e7d8q", "exd8=Q+"},
+		{"3r3k/4P3/8/8/8/8/8/4K3 w - - 0 1", "e7d8n", "exd8=N"},
+		{"3r3k/4P3/8/8/8/8/8/4K3 w - - 0 1", "e7e8r", "e8=R+"},
+
+		// check and mate
+		{"rnbqkbnr/pppp1ppp/8/4p3/6P1/5P2/PPPPP2P/RNBQKBNR b KQkq - 0 2", "d8h4", "Qh4#"},
+		{"rnbqkbnr/pppp1ppp/8/4p3/8/5P2/PPPPP1PP/RNBQKBNR b KQkq - 0 2", "d8h4", "Qh4+"},
+
+		// castling, written king-takes-rook
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "e1h1", "O-O"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "e8a8", "O-O-O"},
+		{"4k3/8/8/8/8/8/8/1R2K1R1 w GB - 0 1", "e1g1", "O-O"},
+		{"4k3/8/8/8/8/8/8/1R2K1R1 w GB - 0 1", "e1b1", "O-O-O"},
+		{"4k3/8/8/8/8/8/8/RK5R w HA - 0 1", "b1a1", "O-O-O"},
+		{"4k3/8/8/8/8/8/8/RK5R w HA - 0 1", "b1h1", "O-O"},
+	} {
+		b := mustFen(t, c.fen)
+		move, err := b.ParseMove(c.move)
+		if err != nil {
+			t.Errorf("%s: %v", c.fen, err)
+			continue
+		}
+
+		if san := b.MoveToSAN(move); san != c.san {
+			t.Errorf("%s: MoveToSAN(%s) = %s, want %s", c.fen, c.move, san, c.san)
+		}
+		if parsed, err := b.ParseSAN(c.san); err != nil || parsed != move {
+			t.Errorf("%s: ParseSAN(%s) = %s, %v, want %s", c.fen, c.san, parsed.MoveToString(), err, c.move)
+		}
+		if fen := b.ToFen(); fen != mustFen(t, c.fen).ToFen() {
+			t.Errorf("%s: board changed to %s", c.fen, fen)
+		}
+	}
+}
+
+// ParseSAN takes what people actually write, not only what MoveToSAN does
+func TestParseSANLenient(t *testing.T) {
+	for _, c := range []struct {
+		fen, san, move string
+	}{
+		{StartFen, "Ng1f3", "g1f3"},
+		{StartFen, "Nf3!?", "g1f3"},
+		{"rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 2", "ed5", "e4d5"},
+		{"3r3k/4P3/8/8/8/8/8/4K3 w - - 0 1", "exd8Q", "e7d8q"},
+		{"3r3k/4P3/8/8/8/8/8/4K3 w - - 0 1", "ed8=Q", "e7d8q"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "0-0-0", "e1a1"},
+	} {
+		b := mustFen(t, c.fen)
+		want, err := b.ParseMove(c.move)
+		if err != nil {
+			t.Fatalf("%s: %v", c.fen, err)
+		}
+		if got, err := b.ParseSAN(c.san); err != nil || got != want {
+			t.Errorf("%s: ParseSAN(%s) = %s, %v, want %s", c.fen, c.san, got.MoveToString(), err, c.move)
+		}
+	}
+}
+
+func TestParseSANErrors(t *testing.T) {
+	for _, c := range []struct {
+		fen, san, err string
+	}{
+		{"4k3/8/8/8/8/8/8/R4RK1 w - - 0 1", "Rd1", "ambiguous"},
+		{"8/8/1k6/8/4Q2Q/8/8/K6Q w - - 0 1", "Qe1", "ambiguous"},
+		{"8/8/1k6/8/4Q2Q/8/8/K6Q w - - 0 1", "Q4e1", "ambiguous"},
+		{"8/8/1k6/8/4Q2Q/8/8/K6Q w - - 0 1", "Qhe1", "ambiguous"},
+		{StartFen, "e5", "illegal"},
+		{StartFen, "O-O", "illegal"},
+		{"3r3k/4P3/8/8/8/8/8/4K3 w - - 0 1", "exd8", "illegal"},
+		{StartFen, "Nz3", "invalid"},
+		{StartFen, "N", "invalid"},
+	} {
+		b := mustFen(t, c.fen)
+		if _, err := b.ParseSAN(c.san); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: ParseSAN(%s) = %v, want %s", c.fen, c.san, err, c.err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package board
+
+import (
+	"bot/moves"
+	"fmt"
+	"sort"
+)
+
+func Perft(b *Board, depth int) uint64 {
+	if depth == 0 {
+		return 1
+	}
+
+	moves := b.Moves(false)
+
+	if depth == 1 {
+		return uint64(moves.Count)
+	}
+
+	var nodes uint64
+	for i := 0; i < moves.Count; i++ {
+		move := moves.Moves[i]
+		b.PlayMove(move)
+		nodes += Perft(b, depth-1)
+		b.UndoMove(move)
+	}
+
+	return nodes
+}
+
+func PerftDivide(b *Board, depth int) uint64 {
+	Moves := b.Moves(false)
+	var totalNodes uint64
+
+	type MoveResult struct {
+		move  moves.Move
+		nodes uint64
+		str   string
+	}
+	results := make([]MoveResult, 0, Moves.Count)
+
+	fmt.Printf("\nPerft Divide (depth %d):\n", depth)
+	fmt.Println("------------------------")
+
+	for i := 0; i < Moves.Count; i++ {
+		move := Moves.Moves[i]
+		b.PlayMove(move)
+
+		var nodes uint64
+		if depth == 1 {
+			nodes = 1
+		} else {
+			nodes = Perft(b, depth-1)
+		}
+
+		b.UndoMove(move)
+
+		moveStr := move.MoveToString()
+		results = append(results, MoveResult{move, nodes, moveStr})
+		totalNodes += nodes
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].str < results[j].str
+	})
+
+	for _, result := range results {
+		fmt.Printf("%s: %d\n", result.str, result.nodes)
+	}
+
+	fmt.Println("------------------------")
+	fmt.Printf("Total: %d\n", totalNodes)
+
+	return totalNodes
+}
+
+// PerftCheckHash walks the same tree as Perft but recomputes the hash from
+// scratch after every PlayMove and UndoMove, stopping at the first position
+// where the incremental hash has drifted
+func PerftCheckHash(b *Board, depth int) (uint64, error) {
+	if b.Hash != CalculateHash(b) {
+		return 0, fmt.Errorf("hash mismatch at root: incremental %x, calculated %x", b.Hash, CalculateHash(b))
+	}
+	return perftCheckHash(b, depth)
+}
+
+func perftCheckHash(b *Board, depth int) (uint64, error) {
+	if depth == 0 {
+		return 1, nil
+	}
+
+	moves := b.Moves(false)
+
+	var nodes uint64
+	for i := 0; i < moves.Count; i++ {
+		move := moves.Moves[i]
+
+		b.PlayMove(move)
+		if want := CalculateHash(b); b.Hash != want {
+			b.UndoMove(move)
+			return nodes, fmt.Errorf("hash mismatch after %s: incremental %x, calculated %x", move.MoveToString(), b.Hash, want)
+		}
+
+		n, err := perftCheckHash(b, depth-1)
+		nodes += n
+
+		b.UndoMove(move)
+		if err != nil {
+			return nodes, fmt.Errorf("%s %w", move.MoveToString(), err)
+		}
+		if want := CalculateHash(b); b.Hash != want {
+			return nodes, fmt.Errorf("hash mismatch undoing %s: incremental %x, calculated %x", move.MoveToString(), b.Hash, want)
+		}
+	}
+
+	return nodes, nil
+}
@@ -0,0 +1,88 @@
+package board
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	InitMagicBitboards()
+	InitZobrist()
+	os.Exit(m.Run())
+}
+
+// mustFen loads fen or fails the test
+func mustFen(t *testing.T, fen string) *Board {
+	t.Helper()
+	var b Board
+	if err := b.FromFen(fen); err != nil {
+		t.Fatalf("FromFen(%q): %v", fen, err)
+	}
+	return &b
+}
+
+type perftCase struct {
+	fen   string
+	depth int
+	nodes uint64
+}
+
+func runPerft(t *testing.T, cases []perftCase) {
+	for _, c := range cases {
+		t.Run(c.fen, func(t *testing.T) {
+			t.Parallel()
+			b := mustFen(t, c.fen)
+			if got := Perft(b, c.depth); got != c.nodes {
+				t.Errorf("perft(%d) = %d, want %d", c.depth, got, c.nodes)
+			}
+			if fen := b.ToFen(); fen != mustFen(t, c.fen).ToFen() {
+				t.Errorf("board not restored after perft, got %s", fen)
+			}
+		})
+	}
+}
+
+// the usual perft positions, https://www.chessprogramming.org/Perft_Results
+func TestPerft(t *testing.T) {
+	runPerft(t, []perftCase{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", 5, 4865609},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", 4, 4085603},
+		{"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", 5, 674624},
+		{"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", 4, 422333},
+		{"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", 4, 2103487},
+		{"r4rk1/1pp1qppp/p1np1n2/2b1p1B1/2B1P1b1/P1NP1N2/1PP1QPPP/R4RK1 w - - 0 10", 4, 3894594},
+	})
+}
+
+// the first positions of the standard chess960 perft suite (fischer.epd),
+// plus the normal start and kiwipete written in Shredder-FEN
+func TestPerftChess960(t *testing.T) {
+	runPerft(t, []perftCase{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w HAha - 0 1", 4, 197281},
+		{"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w HAha - 0 1", 3, 97862},
+		{"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9", 5, 8146062},
+		{"2nnrbkr/p1qppppp/8/1ppb4/6PP/3PP3/PPP2P2/BQNNRBKR w HEhe - 1 9", 4, 667366},
+		{"b1q1rrkb/pppppppp/3nn3/8/P7/1PPP4/4PPPP/BQNNRKRB w GE - 1 9", 5, 6417013},
+		{"qbbnnrkr/2pp2pp/p7/1p2pp2/8/P3PP2/1PPP1KPP/QBBNNR1R w hf - 0 9", 4, 382958},
+		{"1nbbnrkr/p1p1ppp1/3p4/1p3P1p/3Pq2P/8/PPP1P1P1/QNBBNRKR w HFhf - 0 9", 4, 1171749},
+		{"qnbnr1kr/ppp1b1pp/4p3/3p1p2/8/2NPP3/PPP1BPPP/QNB1R1KR w HEhe - 1 9", 4, 824055},
+		{"q1bnrkr1/ppppp2p/2n2p2/4b1p1/2NP4/8/PPP1PPPP/QNB1RRKB w ge - 1 9", 4, 732757},
+		{"qbn1brkr/ppp1p1p1/2n4p/3p1p2/P7/6PP/QPPPPP2/1BNNBRKR w HFhf - 0 9", 4, 465806},
+		{"qnnbbrkr/1p2ppp1/2pp3p/p7/1P5P/2NP4/P1P1PPP1/Q1NBBRKR w HFhf - 0 9", 4, 384260},
+		{"qn1rbbkr/ppp2p1p/1n1pp1p1/8/3P4/P6P/1PP1PPPK/QNNRBB1R w hd - 2 9", 4, 679699},
+	})
+}
+
+// the incremental hash has to match a full recalculation through castling of
+// every kind
+func TestPerftHash(t *testing.T) {
+	for _, fen := range []string{
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9",
+		"qn1rbbkr/ppp2p1p/1n1pp1p1/8/3P4/P6P/1PP1PPPK/QNNRBB1R w hd - 2 9",
+	} {
+		if _, err := PerftCheckHash(mustFen(t, fen), 3); err != nil {
+			t.Errorf("%s: %v", fen, err)
+		}
+	}
+}
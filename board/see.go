@@ -0,0 +1,137 @@
+package board
+
+import (
+	"bot/moves"
+	"math/bits"
+)
+
+// SEEValues are the piece values used by the exchange evaluator, in the usual
+// K, Q, R, B, N, P order
+var SEEValues = [6]int{20000, 900, 500, 330, 320, 100}
+
+func rookAttacksFrom(square int, occ Bitboard) Bitboard {
+	m := rookMagics[square]
+	return rookAttacks[(uint64(occ&m.Mask)*m.Magic>>m.Shift)+uint64(m.Offset)]
+}
+
+func bishopAttacksFrom(square int, occ Bitboard) Bitboard {
+	m := bishopMagics[square]
+	return bishopAttacks[(uint64(occ&m.Mask)*m.Magic>>m.Shift)+uint64(m.Offset)]
+}
+
+// attackersTo returns every piece of either colour attacking square when the
+// board is occupied by occ
+func (b *Board) attackersTo(square int, occ Bitboard) Bitboard {
+	bit := Bitboard(1) << square
+
+	// a white pawn attacks square if a black pawn on square would attack it
+	// back, and the other way round
+	wPawns := (((bit << 7) &^ FileH) | ((bit << 9) &^ FileA)) & b.WPawns
+	bPawns := (((bit >> 7) &^ FileA) | ((bit >> 9) &^ FileH)) & b.BPawns
+
+	return wPawns | bPawns |
+		precomped.Knight[square]&(b.WKnights|b.BKnights) |
+		precomped.King[square]&(b.WKings|b.BKings) |
+		rookAttacksFrom(square, occ)&(b.WRooks|b.BRooks|b.WQueens|b.BQueens) |
+		bishopAttacksFrom(square, occ)&(b.WBishops|b.BBishops|b.WQueens|b.BQueens)
+}
+
+// SEE plays out the exchange on the target square of move, each side always
+// recapturing with its least valuable attacker and free to stop when going on
+// would lose material. the result is the material won by the side making
+// move, negative when it loses. sliders that get uncovered behind a capturing
+// piece join in as x-rays
+func (b *Board) SEE(move moves.Move) int {
+	if move.IsCastling() {
+		return 0
+	}
+
+	from, to := int(move.From()), int(move.To())
+	white := b.Turn
+	pieces := [2][6]Bitboard{
+		{b.WKings, b.WQueens, b.WRooks, b.WBishops, b.WKnights, b.WPawns},
+		{b.BKings, b.BQueens, b.BRooks, b.BBishops, b.BKnights, b.BPawns},
+	}
+	var colours [2]Bitboard
+	for c := range pieces {
+		for _, bb := range pieces[c] {
+			colours[c] |= bb
+		}
+	}
+
+	occ := b.FilledSquares &^ (1 << from)
+	var gain [32]int
+
+	if move.IsEnPassant() {
+		gain[0] = SEEValues[5]
+		if white {
+			occ &^= 1 << (to + 8)
+		} else {
+			occ &^= 1 << (to - 8)
+		}
+	} else if victim := b.Mailbox[to]; victim != -1 {
+		gain[0] = SEEValues[victim%6]
+	}
+
+	onSquare := SEEValues[b.Mailbox[from]%6]
+	if move.IsPromotion() {
+		promoted := move.PromotionPiece()
+		gain[0] += SEEValues[promoted] - SEEValues[5]
+		onSquare = SEEValues[promoted]
+	}
+
+	diagonal := b.WBishops | b.BBishops | b.WQueens | b.BQueens
+	straight := b.WRooks | b.BRooks | b.WQueens | b.BQueens
+	attackers := b.attackersTo(to, occ) & occ
+
+	side := 1 // colour index of the side to recapture
+	if !white {
+		side = 0
+	}
+
+	d := 0
+	for {
+		ours := attackers & colours[side]
+		if ours == 0 {
+			break
+		}
+
+		// least valuable attacker, pawns first and the king last
+		piece := 5
+		for ; piece >= 0; piece-- {
+			if ours&pieces[side][piece] != 0 {
+				break
+			}
+		}
+
+		// the king can only take when nothing is left to take it back
+		if piece == 0 && attackers&colours[side^1] != 0 {
+			break
+		}
+
+		d++
+		gain[d] = onSquare - gain[d-1]
+		onSquare = SEEValues[piece]
+
+		sq := bits.TrailingZeros64(uint64(ours & pieces[side][piece]))
+		occ &^= 1 << sq
+
+		// uncover whatever was lined up behind the piece that just took
+		if piece == 5 || piece == 3 || piece == 1 {
+			attackers |= bishopAttacksFrom(to, occ) & diagonal
+		}
+		if piece == 2 || piece == 1 {
+			attackers |= rookAttacksFrom(to, occ) & straight
+		}
+		attackers &= occ
+
+		side ^= 1
+	}
+
+	// walk back up the sequence, each side taking the better of standing pat
+	// or carrying on
+	for ; d > 0; d-- {
+		gain[d-1] = -max(-gain[d-1], gain[d])
+	}
+	return gain[0]
+}
@@ -0,0 +1,38 @@
+package board
+
+import "testing"
+
+func TestSEE(t *testing.T) {
+	for _, c := range []struct {
+		fen, move string
+		see       int
+	}{
+		// the classic pair, an undefended pawn and a knight lost for one
+		{"1k1r4/1pp4p/p7/4p3/8/P5P1/1PP4P/2K1R3 w - - 0 1", "e1e5", 100},
+		{"1k1r3q/1ppn3p/p4b2/4p3/8/P2N2P1/1PP1R1BP/2K1Q3 w - - 0 1", "d3e5", -220},
+
+		// batteries, the piece behind only joins in as an x-ray
+		{"4k3/8/4r3/4p3/8/8/4R3/4R1K1 w - - 0 1", "e2e5", 100},
+		{"4k3/4q3/4r3/4p3/8/8/4R3/4R1K1 w - - 0 1", "e2e5", -400},
+		{"4k3/8/4p3/3p4/8/5B2/6Q1/6K1 w - - 0 1", "f3d5", -130},
+
+		// en passant takes a pawn that isn't on the target square
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6", 100},
+		{"4k3/2p5/8/3pP3/8/8/8/4K3 w - d6 0 1", "e5d6", 0},
+
+		// the promoted piece is what can be taken back
+		{"3r3k/4P3/8/8/8/8/8/4K3 w - - 0 1", "e7d8q", 1300},
+		{"2rr3k/4P3/8/8/8/8/8/4K3 w - - 0 1", "e7d8q", 400},
+		{"2rr3k/4P3/8/8/8/8/8/4K3 w - - 0 1", "e7d8n", 400},
+	} {
+		b := mustFen(t, c.fen)
+		move, err := b.ParseMove(c.move)
+		if err != nil {
+			t.Errorf("%s: %v", c.fen, err)
+			continue
+		}
+		if see := b.SEE(move); see != c.see {
+			t.Errorf("%s: SEE(%s) = %d, want %d", c.fen, c.move, see, c.see)
+		}
+	}
+}
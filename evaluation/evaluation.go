@@ -6,102 +6,198 @@ import (
 	"math/bits"
 )
 
-var pieceValues [5]int = [5]int{900, 500, 320, 301, 100}
-var PieceSquareTables = [6][64]int{
+// EngineName is what the engine calls itself, to guis and in recorded games
+const EngineName = "bot"
+
+// indexed like AllBitboards: king, queen, rook, bishop, knight, pawn
+var MiddlegameValues = [6]int{0, 900, 500, 330, 320, 100}
+var EndgameValues = [6]int{0, 950, 520, 340, 300, 120}
+
+// how much each piece counts towards the game phase, a full set of pieces
+// adds up to totalPhase
+var phaseWeights = [6]int{0, 4, 2, 1, 1, 0}
+
+const totalPhase = 24
+
+// tables are written from white's side with rank 8 on top, which lines up
+// with square 0 being a8. black looks them up with the rank flipped (sq^56)
+var MiddlegameTables = [6][64]int{
 	// King
 	{
-		20, 30, 10, 0, 0, 10, 30, 20,
-		20, 20, 0, 0, 0, 0, 20, 20,
-		-10, -20, -20, -20, -20, -20, -20, -10,
-		-20, -30, -30, -40, -40, -30, -30, -20,
 		-30, -40, -40, -50, -50, -40, -40, -30,
 		-30, -40, -40, -50, -50, -40, -40, -30,
 		-30, -40, -40, -50, -50, -40, -40, -30,
 		-30, -40, -40, -50, -50, -40, -40, -30,
+		-20, -30, -30, -40, -40, -30, -30, -20,
+		-10, -20, -20, -20, -20, -20, -20, -10,
+		20, 20, 0, 0, 0, 0, 20, 20,
+		20, 30, 10, 0, 0, 10, 30, 20,
 	},
-	//Queen
+	// Queen
 	{
 		-20, -10, -10, -5, -5, -10, -10, -20,
-		-10, 0, 5, 0, 0, 0, 0, -10,
-		-10, 5, 5, 5, 5, 5, 0, -10,
-		0, 0, 5, 5, 5, 5, 0, -5,
-		-5, 0, 5, 5, 5, 5, 0, -5,
-		-10, 0, 5, 5, 5, 5, 0, -10,
 		-10, 0, 0, 0, 0, 0, 0, -10,
+		-10, 0, 5, 5, 5, 5, 0, -10,
+		-5, 0, 5, 5, 5, 5, 0, -5,
+		0, 0, 5, 5, 5, 5, 0, -5,
+		-10, 5, 5, 5, 5, 5, 0, -10,
+		-10, 0, 5, 0, 0, 0, 0, -10,
 		-20, -10, -10, -5, -5, -10, -10, -20,
 	},
 	// Rook
 	{
-		0, 0, 0, 5, 5, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0,
+		5, 10, 10, 10, 10, 10, 10, 5,
 		-5, 0, 0, 0, 0, 0, 0, -5,
 		-5, 0, 0, 0, 0, 0, 0, -5,
 		-5, 0, 0, 0, 0, 0, 0, -5,
 		-5, 0, 0, 0, 0, 0, 0, -5,
 		-5, 0, 0, 0, 0, 0, 0, -5,
-		5, 10, 10, 10, 10, 10, 10, 5,
-		0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 5, 5, 0, 0, 0,
 	},
 	// Bishop
 	{
 		-20, -10, -10, -10, -10, -10, -10, -20,
-		-10, 5, 0, 0, 0, 0, 5, -10,
-		-10, 10, 10, 10, 10, 10, 10, -10,
-		-10, 0, 10, 10, 10, 10, 0, -10,
+		-10, 0, 0, 0, 0, 0, 0, -10,
+		-10, 0, 5, 10, 10, 5, 0, -10,
 		-10, 5, 5, 10, 10, 5, 5, -10,
+		-10, 0, 10, 10, 10, 10, 0, -10,
+		-10, 10, 10, 10, 10, 10, 10, -10,
+		-10, 5, 0, 0, 0, 0, 5, -10,
+		-20, -10, -10, -10, -10, -10, -10, -20,
+	},
+	// Knight
+	{
+		-50, -40, -30, -30, -30, -30, -40, -50,
+		-40, -20, 0, 0, 0, 0, -20, -40,
+		-30, 0, 10, 15, 15, 10, 0, -30,
+		-30, 5, 15, 20, 20, 15, 5, -30,
+		-30, 0, 15, 20, 20, 15, 0, -30,
+		-30, 5, 10, 15, 15, 10, 5, -30,
+		-40, -20, 0, 5, 5, 0, -20, -40,
+		-50, -40, -30, -30, -30, -30, -40, -50,
+	},
+	// Pawn
+	{
+		0, 0, 0, 0, 0, 0, 0, 0,
+		50, 50, 50, 50, 50, 50, 50, 50,
+		10, 10, 20, 30, 30, 20, 10, 10,
+		5, 5, 10, 25, 25, 10, 5, 5,
+		0, 0, 0, 20, 20, 0, 0, 0,
+		5, -5, -10, 0, 0, -10, -5, 5,
+		5, 10, 10, -20, -20, 10, 10, 5,
+		0, 0, 0, 0, 0, 0, 0, 0,
+	},
+}
+
+var EndgameTables = [6][64]int{
+	// King
+	{
+		-50, -40, -30, -20, -20, -30, -40, -50,
+		-30, -20, -10, 0, 0, -10, -20, -30,
+		-30, -10, 20, 30, 30, 20, -10, -30,
+		-30, -10, 30, 40, 40, 30, -10, -30,
+		-30, -10, 30, 40, 40, 30, -10, -30,
+		-30, -10, 20, 30, 30, 20, -10, -30,
+		-30, -30, 0, 0, 0, 0, -30, -30,
+		-50, -30, -30, -30, -30, -30, -30, -50,
+	},
+	// Queen
+	{
+		-20, -10, -10, -5, -5, -10, -10, -20,
+		-10, 0, 0, 0, 0, 0, 0, -10,
+		-10, 0, 5, 5, 5, 5, 0, -10,
+		-5, 0, 5, 10, 10, 5, 0, -5,
+		-5, 0, 5, 10, 10, 5, 0, -5,
+		-10, 0, 5, 5, 5, 5, 0, -10,
+		-10, 0, 0, 0, 0, 0, 0, -10,
+		-20, -10, -10, -5, -5, -10, -10, -20,
+	},
+	// Rook
+	{
+		0, 0, 0, 0, 0, 0, 0, 0,
+		10, 10, 10, 10, 10, 10, 10, 10,
+		0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0,
+	},
+	// Bishop
+	{
+		-20, -10, -10, -10, -10, -10, -10, -20,
+		-10, 0, 0, 0, 0, 0, 0, -10,
+		-10, 0, 5, 5, 5, 5, 0, -10,
+		-10, 0, 5, 10, 10, 5, 0, -10,
 		-10, 0, 5, 10, 10, 5, 0, -10,
+		-10, 0, 5, 5, 5, 5, 0, -10,
 		-10, 0, 0, 0, 0, 0, 0, -10,
 		-20, -10, -10, -10, -10, -10, -10, -20,
 	},
 	// Knight
 	{
 		-50, -40, -30, -30, -30, -30, -40, -50,
-		-40, -20, 0, 5, 5, 0, -20, -40,
-		-30, 5, 10, 15, 15, 10, 5, -30,
-		-30, 0, 15, 20, 20, 15, 0, -30,
-		-30, 5, 15, 20, 20, 15, 5, -30,
-		-30, 0, 10, 15, 15, 10, 0, -30,
 		-40, -20, 0, 0, 0, 0, -20, -40,
+		-30, 0, 10, 15, 15, 10, 0, -30,
+		-30, 5, 15, 20, 20, 15, 5, -30,
+		-30, 0, 15, 20, 20, 15, 0, -30,
+		-30, 5, 10, 15, 15, 10, 5, -30,
+		-40, -20, 0, 5, 5, 0, -20, -40,
 		-50, -40, -30, -30, -30, -30, -40, -50,
 	},
 	// Pawn
 	{
 		0, 0, 0, 0, 0, 0, 0, 0,
-		5, 10, 10, -20, -20, 10, 10, 5,
-		5, -5, -10, 0, 0, -10, -5, 5,
-		0, 0, 0, 20, 20, 0, 0, 0,
-		5, 5, 10, 25, 25, 10, 5, 5,
-		10, 10, 20, 30, 30, 20, 10, 10,
+		80, 80, 80, 80, 80, 80, 80, 80,
 		50, 50, 50, 50, 50, 50, 50, 50,
+		30, 30, 30, 30, 30, 30, 30, 30,
+		15, 15, 15, 15, 15, 15, 15, 15,
+		5, 5, 5, 5, 5, 5, 5, 5,
+		0, 0, 0, 0, 0, 0, 0, 0,
 		0, 0, 0, 0, 0, 0, 0, 0,
 	},
 }
 
-type EntryFlag int
-
 const (
-	Exact EntryFlag = iota
-	Alpha
-	Beta
+	Infinity  = 32000
+	MateScore = 30000
+	// anything above MateBound is a forced mate found by the search
+	MateBound = MateScore - MaxDepth
 )
 
-type TTEntry struct {
-	Depth int
-	Score int
-	Flag  EntryFlag // exact, alpha, beta
+// MateIn turns a mate score into moves until mate, negative when we are
+// the side getting mated
+func MateIn(score int) (int, bool) {
+	switch {
+	case score > MateBound:
+		return (MateScore - score + 1) / 2, true
+	case score < -MateBound:
+		return -(MateScore + score + 1) / 2, true
+	}
+	return 0, false
 }
 
-var TranspositionTable map[board.Bitboard]TTEntry = make(map[board.Bitboard]TTEntry)
-
-func StoreTT(hash board.Bitboard, entry TTEntry) {
-	TranspositionTable[hash] = entry
+// mate scores are stored relative to the node rather than the root, so the
+// same position found at a different ply still gets the right distance
+func scoreToTT(score, ply int) int {
+	if score > MateBound {
+		return score + ply
+	}
+	if score < -MateBound {
+		return score - ply
+	}
+	return score
 }
 
-func LookupTT(hash board.Bitboard, depth int) (TTEntry, bool) {
-	entry, ok := TranspositionTable[hash]
-	if ok && entry.Depth >= depth {
-		return entry, true
+func scoreFromTT(score, ply int) int {
+	if score > MateBound {
+		return score - ply
+	}
+	if score < -MateBound {
+		return score + ply
 	}
-	return TTEntry{}, false
+	return score
 }
 
 var edges board.Bitboard = 0xff818181818181ff
@@ -109,28 +205,36 @@ var center board.Bitboard = 0x1818000000
 
 //var kingHeatMap [64]int =
 
+// Evaluate scores the position for the side to move. middlegame and endgame
+// scores are kept apart and blended by how much material is left, so the king
+// walks to the centre and pawns push once the pieces come off
 func Evaluate(b *board.Board) int {
-	var score int = 0
+	var mg, eg, phase int
 
-	for i := 0; i < 6; i++ {
-		for bb := *b.AllBitboards[i]; bb != 0; bb &= bb - 1 {
+	for piece := 0; piece < 6; piece++ {
+		for bb := *b.AllBitboards[piece]; bb != 0; bb &= bb - 1 {
 			square := bits.TrailingZeros64(uint64(bb))
-			if i == 5 {
-				//fmt.Println("Piece", i, "square", square, "PST", PieceSquareTables[i][square])
-			}
-
-			score += PieceSquareTables[i][square] //pieceValues[i] +
+			mg += MiddlegameValues[piece] + MiddlegameTables[piece][square]
+			eg += EndgameValues[piece] + EndgameTables[piece][square]
+			phase += phaseWeights[piece]
 		}
-	}
 
-	for i := 6; i < 12; i++ {
-		for bb := *b.AllBitboards[i]; bb != 0; bb &= bb - 1 {
-			square := bits.TrailingZeros64(uint64(bb))
-			score -= PieceSquareTables[i-6][square] //pieceValues[i-6] +
+		for bb := *b.AllBitboards[piece+6]; bb != 0; bb &= bb - 1 {
+			square := bits.TrailingZeros64(uint64(bb)) ^ 56
+			mg -= MiddlegameValues[piece] + MiddlegameTables[piece][square]
+			eg -= EndgameValues[piece] + EndgameTables[piece][square]
+			phase += phaseWeights[piece]
 		}
 	}
 
-	return score * -1
+	// early promotions can push the phase past a full board
+	phase = min(phase, totalPhase)
+	score := (mg*phase + eg*(totalPhase-phase)) / totalPhase
+
+	if !b.Turn {
+		return -score
+	}
+	return score
 }
 
 func evaluatePiecePositions(b *board.Board) int {
@@ -148,45 +252,262 @@ func evaluatePiecePositions(b *board.Board) int {
 	return score
 }
 
-func Search(b *board.Board, depth int, alpha int, beta int) int {
+// null move pruning settings
+var (
+	NullMoveMinDepth  = 3
+	NullMoveReduction = 2
+)
+
+// hasPieces is true when the side to move has anything besides its king and
+// pawns
+func hasPieces(b *board.Board) bool {
+	if b.Turn {
+		return b.WQueens|b.WRooks|b.WBishops|b.WKnights != 0
+	}
+	return b.BQueens|b.BRooks|b.BBishops|b.BKnights != 0
+}
+
+func (t *thread) Search(b *board.Board, depth int, ply int, alpha int, beta int) int {
+	t.nodes.Add(1)
+	t.pvLength[ply] = ply
+	t.selDepth = max(t.selDepth, ply)
+	if t.checkStop() {
+		return 0
+	}
+	if ply >= MaxDepth {
+		return Evaluate(b)
+	}
+
+	// inside the tree a single repetition is enough, if it was good the side
+	// to move could repeat again and claim the draw anyway
+	if ply > 0 && (b.Repetitions() >= 2 || b.IsInsufficientMaterial()) {
+		return 0
+	}
+	// a mate delivered on the hundredth half move still wins
+	if ply > 0 && b.IsFiftyMoveDraw() && !(b.IsKingAttacked() && b.Moves(false).Count == 0) {
+		return 0
+	}
+
 	ogalpha := alpha
-	if entry, ok := LookupTT(b.Hash, depth); ok {
+	var ttMove, bestMove moves.Move
+	var quiets [218]moves.Move
+
+	// a singular verification search shares the hash of the real node, so it
+	// must neither take cutoffs from the tt nor store into it. pv nodes
+	// search on as well, a cutoff there would cut the pv short
+	pvNode := beta-alpha > 1
+	skip := t.excluded[ply]
+	entry, ttHit := ProbeTT(b.Hash)
+	if ttHit && skip == 0 && !pvNode && entry.Depth >= depth {
+		score := scoreFromTT(entry.Score, ply)
 		switch entry.Flag {
 		case Exact:
-			return entry.Score
+			return score
 		case Alpha:
-			if entry.Score <= alpha {
+			if score <= alpha {
 				return alpha
 			}
 		case Beta:
-			if entry.Score >= beta {
+			if score >= beta {
 				return beta
 			}
 		}
 	}
+	if ttHit {
+		ttMove = entry.Move
+	}
 
 	if depth == 0 {
-		return SearchAllCaptures(b, alpha, beta)
+		return t.SearchAllCaptures(b, ply, alpha, beta)
+	}
+
+	inCheck := b.IsKingAttacked()
+	staticEval := -Infinity
+	if !inCheck {
+		staticEval = Evaluate(b)
+	}
+
+	// reverse futility: the static eval is so far above beta that a shallow
+	// search is not going to bring it back down
+	if !pvNode && !inCheck && skip == 0 && depth <= RFPMaxDepth && abs(beta) < MateBound &&
+		staticEval-RFPMargin*depth >= beta {
+		return staticEval
+	}
+
+	// razoring: hopelessly below alpha, see if a capture can save it and
+	// otherwise give up on the node
+	if !pvNode && !inCheck && skip == 0 && depth <= RazorMaxDepth && staticEval+RazorMargin*depth < alpha {
+		value := t.SearchAllCaptures(b, ply, alpha-1, alpha)
+		if stopped.Load() {
+			return 0
+		}
+		if value < alpha {
+			return value
+		}
+	}
+
+	// null move pruning: if passing still fails high the position is good
+	// enough to cut. not in check (passing would be illegal), not twice in a
+	// row and not with only pawns left, where zugzwang makes passing a lie
+	if !pvNode && !inCheck && skip == 0 && depth >= NullMoveMinDepth && b.LastMove() != 0 &&
+		hasPieces(b) && staticEval >= beta {
+		r := NullMoveReduction + depth/6
+		b.MakeNullMove()
+		value := -t.Search(b, max(depth-1-r, 0), ply+1, -beta, -beta+1)
+		b.UnmakeNullMove()
+
+		if stopped.Load() {
+			return 0
+		}
+		if value >= beta {
+			// don't trust mate scores from a position that passed
+			if value >= MateBound {
+				value = beta
+			}
+			return value
+		}
+	}
+
+	// singular extension: if every other move falls well short of the tt
+	// score, the tt move is the only one holding the position and earns an
+	// extra ply. if even without it the search beats beta, several moves
+	// refute the parent and the node can be cut (multi-cut)
+	singular := false
+	if ttHit && skip == 0 && ply > 0 && depth >= SingularMinDepth && ttMove != 0 &&
+		entry.Flag != Alpha && entry.Depth >= depth-3 && t.canExtend(ply) {
+		ttScore := scoreFromTT(entry.Score, ply)
+		if abs(ttScore) < MateBound {
+			singularBeta := ttScore - SingularMargin*depth
+			t.excluded[ply] = ttMove
+			value := t.Search(b, (depth-1)/2, ply, singularBeta-1, singularBeta)
+			t.excluded[ply] = 0
+			t.pvLength[ply] = ply
+
+			if stopped.Load() {
+				return 0
+			}
+			if value < singularBeta {
+				singular = true
+			} else if singularBeta >= beta {
+				t.extensions.MultiCut++
+				return singularBeta
+			}
+		}
 	}
 
 	moves := b.Moves(false)
+	if moves.Count == 0 {
+		if skip != 0 {
+			// the excluded move was the only one
+			return alpha
+		}
+		if inCheck {
+			// mated, the further away the mate the better for us
+			return -MateScore + ply
+		}
+		return 0 // stalemate
+	}
+
+	t.OrderMoves(b, &moves, ttMove, ply)
+	t.orderPV(&moves, ply)
+
+	quietCount := 0
+
+	// futility pruning: at the frontier a quiet move won't lift a position
+	// this far below alpha, unless it gives check
+	futile := !pvNode && !inCheck && depth <= FutilityMaxDepth && alpha > -MateBound &&
+		staticEval+FutilityMargin*depth <= alpha
+
+	searched := 0
 	for i := 0; i < moves.Count; i++ {
 		move := moves.Moves[i]
+		if move == skip {
+			continue
+		}
+		quiet := isQuiet(b, move)
+
+		// late move pruning: near the leaves quiet moves this far down the
+		// list almost never matter
+		if quiet && !pvNode && !inCheck && depth <= LMPMaxDepth && i > 0 &&
+			quietCount >= lmpThreshold(depth) && alpha > -MateBound {
+			continue
+		}
+
+		moveHistory := t.history[side(b)][move.From()][move.To()]
 
 		b.PlayMove(move)
-		value := -Search(b, depth-1, -beta, -alpha)
+		givesCheck := b.IsKingAttacked()
+		if futile && quiet && i > 0 && !givesCheck {
+			b.UndoMove(move)
+			continue
+		}
+
+		if quiet {
+			quiets[quietCount] = move
+			quietCount++
+		}
+
+		// one ply at most per move, checks first
+		ext := 0
+		if t.canExtend(ply) {
+			if givesCheck {
+				ext = 1
+				t.extensions.Check++
+			} else if singular && move == ttMove {
+				ext = 1
+				t.extensions.Singular++
+			}
+		}
+		newDepth := depth - 1 + ext
+
+		var value int
+		if searched == 0 {
+			value = -t.Search(b, newDepth, ply+1, -beta, -alpha)
+		} else {
+			r := 0
+			if quiet && depth >= LMRMinDepth && i >= LMRMinMoves && !inCheck && !givesCheck {
+				r = reduction(depth, i, moveHistory, pvNode)
+			}
+
+			// pvs: prove the move is no better than alpha with a null window
+			// and only pay for a full search when that fails. reduced moves
+			// that fail high get another look at full depth first
+			value = -t.Search(b, newDepth-r, ply+1, -alpha-1, -alpha)
+			if r > 0 && value > alpha {
+				value = -t.Search(b, newDepth, ply+1, -alpha-1, -alpha)
+			}
+			if value > alpha && value < beta {
+				value = -t.Search(b, newDepth, ply+1, -beta, -alpha)
+			}
+		}
 		b.UndoMove(move)
+		searched++
+
+		// an aborted subtree returns garbage, so it must not reach the tt
+		if stopped.Load() {
+			return 0
+		}
 
 		if value >= beta {
-			StoreTT(b.Hash, TTEntry{
-				Depth: depth,
-				Score: value,
-				Flag:  Beta,
-			})
+			if quiet {
+				t.storeCutoff(b, move, quiets[:quietCount], depth, ply)
+			}
+			if skip == 0 {
+				StoreTT(b.Hash, TTEntry{
+					Depth: depth,
+					Score: scoreToTT(value, ply),
+					Flag:  Beta,
+					Move:  move,
+				})
+			}
 			return value
 		}
 
-		alpha = max(alpha, value)
+		if value > alpha {
+			alpha = value
+			bestMove = move
+			t.updatePV(ply, move)
+		}
 	}
 
 	flag := Exact
@@ -196,31 +517,66 @@ func Search(b *board.Board, depth int, alpha int, beta int) int {
 		flag = Beta
 	}
 
-	StoreTT(b.Hash, TTEntry{
-		Depth: depth,
-		Score: alpha,
-		Flag:  flag,
-	})
+	if skip == 0 {
+		StoreTT(b.Hash, TTEntry{
+			Depth: depth,
+			Score: scoreToTT(alpha, ply),
+			Flag:  flag,
+			Move:  bestMove,
+		})
+	}
 
 	return alpha
 }
 
-func SearchAllCaptures(b *board.Board, alpha int, beta int) int {
+func (t *thread) SearchAllCaptures(b *board.Board, ply int, alpha int, beta int) int {
+	t.nodes.Add(1)
+	t.selDepth = max(t.selDepth, ply)
+	if t.checkStop() {
+		return 0
+	}
 	if entry, ok := LookupTT(b.Hash, 1); ok {
+		score := scoreFromTT(entry.Score, ply)
 		switch entry.Flag {
 		case Exact:
-			return entry.Score
+			return score
 		case Alpha:
-			if entry.Score <= alpha {
+			if score <= alpha {
 				return alpha
 			}
 		case Beta:
-			if entry.Score >= beta {
+			if score >= beta {
 				return beta
 			}
 		}
 	}
 
+	// in check every evasion has to be looked at, standing pat would be
+	// pretending the check isn't there
+	if b.IsKingAttacked() {
+		evasions := b.Moves(false)
+		if evasions.Count == 0 {
+			return -MateScore + ply
+		}
+		t.OrderMoves(b, &evasions, 0, ply)
+		for i := 0; i < evasions.Count; i++ {
+			move := evasions.Moves[i]
+
+			b.PlayMove(move)
+			value := -t.SearchAllCaptures(b, ply+1, -beta, -alpha)
+			b.UndoMove(move)
+
+			if stopped.Load() {
+				return 0
+			}
+			if value >= beta {
+				return value
+			}
+			alpha = max(alpha, value)
+		}
+		return alpha
+	}
+
 	eval := Evaluate(b)
 	if eval >= beta {
 		return beta
@@ -228,13 +584,35 @@ func SearchAllCaptures(b *board.Board, alpha int, beta int) int {
 	alpha = max(alpha, eval)
 
 	capturemoves := b.Moves(true)
+	t.OrderMoves(b, &capturemoves, 0, ply)
 	for i := 0; i < capturemoves.Count; i++ {
 		move := capturemoves.Moves[i]
 
+		// delta pruning: even winning the piece for free plus a margin
+		// doesn't reach alpha
+		if !move.IsPromotion() {
+			victim := 5
+			if !move.IsEnPassant() {
+				victim = int(b.PieceAt(move.To()) % 6)
+			}
+			if eval+MiddlegameValues[victim]+DeltaMargin <= alpha {
+				continue
+			}
+		}
+
+		// captures that lose material on the exchange are left out
+		if b.SEE(move) < 0 {
+			continue
+		}
+
 		b.PlayMove(move)
-		value := -SearchAllCaptures(b, -beta, -alpha)
+		value := -t.SearchAllCaptures(b, ply+1, -beta, -alpha)
 		b.UndoMove(move)
 
+		if stopped.Load() {
+			return 0
+		}
+
 		if value >= beta {
 			return value
 		}
@@ -245,28 +623,101 @@ func SearchAllCaptures(b *board.Board, alpha int, beta int) int {
 	return alpha
 }
 
-func FindBestMove(b *board.Board, depth int) moves.Move {
+// AspirationWindow is the first half width tried around the previous score
+var AspirationWindow = 25
+
+// FindBestMove runs a single fixed depth search on the main thread
+func FindBestMove(b *board.Board, depth int) (moves.Move, int) {
+	return threads[0].searchRoot(b, depth, -Infinity, Infinity)
+}
+
+// aspirationSearch starts with a narrow window around the last iteration's
+// score and widens it on whichever side the search falls out of
+func (t *thread) aspirationSearch(b *board.Board, depth int, prevScore int) (moves.Move, int) {
+	if depth < 4 || abs(prevScore) > MateBound {
+		return t.searchRoot(b, depth, -Infinity, Infinity)
+	}
+
+	delta := AspirationWindow
+	alpha := max(prevScore-delta, -Infinity)
+	beta := min(prevScore+delta, Infinity)
+
+	for {
+		move, score := t.searchRoot(b, depth, alpha, beta)
+		if stopped.Load() {
+			return move, score
+		}
+
+		switch {
+		case score <= alpha:
+			alpha = max(score-delta, -Infinity)
+		case score >= beta:
+			beta = min(score+delta, Infinity)
+		default:
+			return move, score
+		}
+
+		delta *= 2
+		if delta > 1000 {
+			alpha, beta = -Infinity, Infinity
+		}
+	}
+}
 
+func (t *thread) searchRoot(b *board.Board, depth int, alpha int, beta int) (moves.Move, int) {
 	var bestMove moves.Move
-	alpha := -9999
-	beta := 9999
+	t.rootDepth = depth
+
+	var ttMove moves.Move
+	if entry, ok := ProbeTT(b.Hash); ok {
+		ttMove = entry.Move
+	}
 
 	moves := b.Moves(false)
-	//OrderMoves(b, &moves)
+	t.OrderMoves(b, &moves, ttMove, 0)
+	t.pvLength[0] = 0
+	t.followPV = true
+	t.orderPV(&moves, 0)
+	if moves.Count > 0 {
+		bestMove = moves.Moves[0]
+	}
 
 	for i := 0; i < moves.Count; i++ {
 		move := moves.Moves[i]
 
 		b.PlayMove(move)
-		moveValue := -Search(b, depth-1, -beta, -alpha)
-		//fmt.Println("Move:", move.MoveToString(), "Value:", moveValue)
+		var moveValue int
+		if i == 0 {
+			moveValue = -t.Search(b, depth-1, 1, -beta, -alpha)
+		} else {
+			moveValue = -t.Search(b, depth-1, 1, -alpha-1, -alpha)
+			if moveValue > alpha && moveValue < beta {
+				moveValue = -t.Search(b, depth-1, 1, -beta, -alpha)
+			}
+		}
 		b.UndoMove(move)
 
+		if stopped.Load() {
+			break
+		}
+
 		if moveValue > alpha {
 			alpha = moveValue
 			bestMove = move
+			t.updatePV(0, move)
+		}
+
+		if alpha >= beta {
+			break
 		}
 	}
 
-	return bestMove
+	return bestMove, alpha
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
 }
@@ -0,0 +1,26 @@
+package evaluation
+
+// singular extension settings
+var (
+	SingularMinDepth = 8
+	SingularMargin   = 2 // centipawns below the tt score per ply of depth
+)
+
+// ExtensionStats counts how often each extension fired during a search
+type ExtensionStats struct {
+	Check    uint64
+	Singular uint64
+	MultiCut uint64
+}
+
+func (s *ExtensionStats) add(other ExtensionStats) {
+	s.Check += other.Check
+	s.Singular += other.Singular
+	s.MultiCut += other.MultiCut
+}
+
+// canExtend caps extensions so a line can grow to at most twice the
+// iteration depth, otherwise long checking sequences blow the tree up
+func (t *thread) canExtend(ply int) bool {
+	return ply < 2*t.rootDepth
+}
@@ -0,0 +1,226 @@
+package evaluation
+
+import (
+	"bot/board"
+	"bot/moves"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Limits says when a search should stop, zero fields are unlimited
+type Limits struct {
+	Depth    int
+	Nodes    uint64
+	MoveTime time.Duration
+	Mate     int // find a mate in this many moves
+	Clock    Clock
+
+	// Ponder, if not nil, makes this a search on the opponent's time. the
+	// time limits only start counting once it is closed (ponderhit)
+	Ponder <-chan struct{}
+}
+
+type Result struct {
+	Move     moves.Move
+	Score    int
+	Depth    int
+	SelDepth int
+	PV       []moves.Move
+	Nodes    uint64
+	Time     time.Duration
+
+	Extensions ExtensionStats
+}
+
+// stopped is shared by every thread, the first one to hit a limit stops them
+// all. the other limits are set before the threads start and only read
+var stopped atomic.Bool
+var softLimit, hardLimit time.Duration
+var nodeLimit uint64
+var searchCtx context.Context
+
+// the time limits count from clockStart, which a ponderhit moves to the
+// moment the expected move was actually played
+var clockStart atomic.Int64
+var pondering atomic.Bool
+
+// watchPonder turns the ponder search into a normal timed one when ponder is
+// closed. it returns a function that stops watching
+func watchPonder(ponder <-chan struct{}) (stop func()) {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ponder:
+			clockStart.Store(time.Now().UnixNano())
+			pondering.Store(false)
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+func elapsed() time.Duration {
+	return time.Duration(time.Now().UnixNano() - clockStart.Load())
+}
+
+// checkStop is polled from inside the search. the clock and context are only
+// looked at every 2048 nodes since both are far slower than a node
+func (t *thread) checkStop() bool {
+	if stopped.Load() {
+		return true
+	}
+
+	// with helpers running the node count has to be summed over every
+	// thread, so it is only done every 64 nodes
+	nodes := t.nodes.Load()
+	if nodeLimit > 0 && (len(threads) == 1 || nodes&63 == 0) && totalNodes() >= nodeLimit {
+		stopped.Store(true)
+		return true
+	}
+	if nodes&2047 != 0 {
+		return false
+	}
+
+	if hardLimit > 0 && !pondering.Load() && elapsed() >= hardLimit {
+		stopped.Store(true)
+	}
+	if searchCtx != nil {
+		select {
+		case <-searchCtx.Done():
+			stopped.Store(true)
+		default:
+		}
+	}
+	return stopped.Load()
+}
+
+// SearchContext deepens one ply at a time until a limit is hit or ctx is
+// cancelled. the move from the last completed depth is returned, so the
+// result is always usable even when the search was cut short. onIteration,
+// if not nil, is called after every completed depth.
+//
+// helper threads search the same position alongside the main thread, only
+// the main thread's iterations are reported and its move is the one played
+func SearchContext(ctx context.Context, b *board.Board, limits Limits, onIteration func(Result)) Result {
+	start := time.Now()
+
+	maxDepth := MaxDepth
+	if limits.Depth > 0 {
+		maxDepth = min(limits.Depth, MaxDepth)
+	}
+	if limits.Mate > 0 {
+		// a mate in n takes 2n-1 plies, plus one to see the mated side has
+		// no moves left
+		maxDepth = min(maxDepth, 2*limits.Mate)
+	}
+
+	softLimit, hardLimit = 0, 0
+	if limits.Clock.IsSet() {
+		softLimit, hardLimit = limits.Clock.Budget(b.Turn)
+	}
+	if limits.MoveTime > 0 {
+		t := max(limits.MoveTime-MoveOverhead, time.Millisecond)
+		softLimit, hardLimit = t, t
+	}
+
+	NewSearchTT()
+	for _, t := range threads {
+		t.prepare(b)
+	}
+	stopped.Store(false)
+	nodeLimit = limits.Nodes
+	searchCtx = ctx
+	clockStart.Store(start.UnixNano())
+	pondering.Store(limits.Ponder != nil)
+	if limits.Ponder != nil {
+		defer watchPonder(limits.Ponder)()
+	}
+	defer func() {
+		stopped.Store(false)
+		nodeLimit = 0
+		searchCtx = nil
+		softLimit, hardLimit = 0, 0
+		pondering.Store(false)
+	}()
+
+	var helpers sync.WaitGroup
+	for _, t := range threads[1:] {
+		helpers.Add(1)
+		go func() {
+			defer helpers.Done()
+			t.helperSearch(maxDepth)
+		}()
+	}
+
+	main := threads[0]
+	var result Result
+
+	for depth := 1; depth <= maxDepth; depth++ {
+		main.selDepth = 0
+		move, score := main.aspirationSearch(main.board, depth, result.Score)
+		if stopped.Load() {
+			// an aborted first iteration is still better than no move at all
+			if result.Depth == 0 {
+				result.Move, result.Score = move, score
+				result.PV = []moves.Move{move}
+			}
+			break
+		}
+
+		result.Move, result.Score, result.Depth = move, score, depth
+		result.SelDepth = main.selDepth
+		result.PV = main.principalVariation()
+		result.Nodes = totalNodes()
+		result.Time = time.Since(start)
+		main.prevPV = result.PV
+
+		if onIteration != nil {
+			onIteration(result)
+		}
+
+		if n, ok := MateIn(score); ok && n > 0 && limits.Mate > 0 && n <= limits.Mate {
+			break
+		}
+
+		if softLimit > 0 && !pondering.Load() && elapsed() >= softLimit {
+			break
+		}
+	}
+
+	stopped.Store(true)
+	helpers.Wait()
+
+	result.Nodes = totalNodes()
+	result.Time = time.Since(start)
+	for _, t := range threads {
+		result.Extensions.add(t.extensions)
+	}
+	return result
+}
+
+// SearchLimits is SearchContext for a search that is never cancelled
+func SearchLimits(b *board.Board, limits Limits) Result {
+	return SearchContext(context.Background(), b, limits, nil)
+}
+
+// helperSearch is the iterative deepening loop of a helper thread. odd
+// helpers start a ply deeper so the threads spread over different depths
+// instead of all searching the same tree in lockstep
+func (t *thread) helperSearch(maxDepth int) {
+	score := 0
+	for depth := 1 + t.id%2; depth <= maxDepth; depth++ {
+		move, s := t.aspirationSearch(t.board, depth, score)
+		if stopped.Load() || move == 0 {
+			return
+		}
+		score = s
+		t.prevPV = t.principalVariation()
+	}
+}
@@ -0,0 +1,160 @@
+package evaluation
+
+import (
+	"bot/board"
+	"bot/moves"
+)
+
+// ordering scores, every band sits above the largest value of the one below
+const (
+	ttMoveScore     = 1_000_000
+	captureScore    = 200_000
+	promotionScore  = 150_000
+	killerScore     = 90_000
+	counterScore    = 80_000
+	MaxHistoryScore = 16_384
+)
+
+// ClearHistory forgets all ordering statistics, used between games
+func ClearHistory() {
+	for _, t := range threads {
+		t.killers = [MaxDepth + 2][2]moves.Move{}
+		t.history = [2][64][64]int{}
+		t.counterMoves = [12][64]moves.Move{}
+	}
+}
+
+func side(b *board.Board) int {
+	if b.Turn {
+		return 0
+	}
+	return 1
+}
+
+// isQuiet is true for moves that neither capture nor promote. castling is
+// stored as king-takes-rook so it has to be excluded from captures
+func isQuiet(b *board.Board, move moves.Move) bool {
+	if move.IsPromotion() || move.IsEnPassant() {
+		return false
+	}
+	return move.IsCastling() || b.PieceAt(move.To()) == -1
+}
+
+// counterMove looks up the stored reply to the move that was just played
+func (t *thread) counterMove(b *board.Board) moves.Move {
+	last := b.LastMove()
+	if last == 0 {
+		return 0
+	}
+	piece := b.PieceAt(last.To())
+	if piece == -1 {
+		return 0
+	}
+	return t.counterMoves[piece][last.To()]
+}
+
+func (t *thread) scoreMove(b *board.Board, move, ttMove, counter moves.Move, ply int) int {
+	if move == ttMove {
+		return ttMoveScore
+	}
+
+	if !isQuiet(b, move) {
+		score := 0
+		if move.IsPromotion() {
+			score = promotionScore + MiddlegameValues[move.PromotionPiece()]
+		}
+
+		// mvv-lva, the most valuable victim first and the cheapest attacker
+		// breaking ties
+		victim := b.PieceAt(move.To())
+		if move.IsEnPassant() {
+			victim = 5
+		}
+		if victim != -1 {
+			attacker := b.PieceAt(move.From())
+			score = max(score, captureScore+MiddlegameValues[victim%6]*10-MiddlegameValues[attacker%6]/10)
+		}
+		return score
+	}
+
+	if ply < len(t.killers) {
+		switch move {
+		case t.killers[ply][0]:
+			return killerScore
+		case t.killers[ply][1]:
+			return killerScore - 1
+		}
+	}
+	if move == counter {
+		return counterScore
+	}
+	return t.history[side(b)][move.From()][move.To()]
+}
+
+// OrderMoves sorts ml so the moves most likely to cause a cutoff come first:
+// the hash move, captures by mvv-lva, promotions, killers, the counter move
+// and finally quiet moves by their history score
+func (t *thread) OrderMoves(b *board.Board, ml *moves.MoveList, ttMove moves.Move, ply int) {
+	var scores [218]int
+	counter := t.counterMove(b)
+	for i := 0; i < ml.Count; i++ {
+		scores[i] = t.scoreMove(b, ml.Moves[i], ttMove, counter, ply)
+	}
+
+	// insertion sort, move lists are short and often nearly sorted
+	for i := 1; i < ml.Count; i++ {
+		move, score := ml.Moves[i], scores[i]
+		j := i - 1
+		for j >= 0 && scores[j] < score {
+			ml.Moves[j+1], scores[j+1] = ml.Moves[j], scores[j]
+			j--
+		}
+		ml.Moves[j+1], scores[j+1] = move, score
+	}
+}
+
+// updateHistory rewards the quiet move that failed high and punishes the
+// quiet moves tried before it. the gravity term keeps scores inside
+// MaxHistoryScore so they never climb into the killer band
+func (t *thread) updateHistory(b *board.Board, best moves.Move, tried []moves.Move, depth int) {
+	bonus := min(depth*depth, MaxHistoryScore)
+	s := side(b)
+
+	for _, move := range tried {
+		h := &t.history[s][move.From()][move.To()]
+		if move == best {
+			*h += bonus - *h*bonus/MaxHistoryScore
+		} else {
+			*h -= bonus + *h*bonus/MaxHistoryScore
+		}
+	}
+}
+
+// ageHistory runs before every search. killers only make sense for the tree
+// they came from, history is halved so older searches count for less
+func (t *thread) ageHistory() {
+	t.killers = [MaxDepth + 2][2]moves.Move{}
+	for s := range t.history {
+		for from := range t.history[s] {
+			for to := range t.history[s][from] {
+				t.history[s][from][to] /= 2
+			}
+		}
+	}
+}
+
+// storeCutoff records a quiet move that caused a beta cutoff
+func (t *thread) storeCutoff(b *board.Board, move moves.Move, tried []moves.Move, depth, ply int) {
+	if t.killers[ply][0] != move {
+		t.killers[ply][1] = t.killers[ply][0]
+		t.killers[ply][0] = move
+	}
+
+	if last := b.LastMove(); last != 0 {
+		if piece := b.PieceAt(last.To()); piece != -1 {
+			t.counterMoves[piece][last.To()] = move
+		}
+	}
+
+	t.updateHistory(b, move, tried, depth)
+}
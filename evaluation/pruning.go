@@ -0,0 +1,18 @@
+package evaluation
+
+// margins for the shallow depth pruning in Search, all in centipawns per ply
+// of remaining depth
+var (
+	RFPMaxDepth = 6
+	RFPMargin   = 80
+
+	FutilityMaxDepth = 3
+	FutilityMargin   = 120
+
+	RazorMaxDepth = 2
+	RazorMargin   = 250
+)
+
+// DeltaMargin is the slack given to a capture in quiescence before it is
+// pruned as unable to raise alpha
+var DeltaMargin = 200
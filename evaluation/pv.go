@@ -0,0 +1,41 @@
+package evaluation
+
+import "bot/moves"
+
+func (t *thread) updatePV(ply int, move moves.Move) {
+	t.pvTable[ply][ply] = move
+	for i := ply + 1; i < t.pvLength[ply+1]; i++ {
+		t.pvTable[ply][i] = t.pvTable[ply+1][i]
+	}
+	t.pvLength[ply] = max(t.pvLength[ply+1], ply+1)
+}
+
+// orderPV puts the previous iteration's move for this ply in front, shifting
+// the others down so the rest of the ordering is kept
+func (t *thread) orderPV(ml *moves.MoveList, ply int) {
+	if !t.followPV {
+		return
+	}
+	t.followPV = false
+	if ply >= len(t.prevPV) {
+		return
+	}
+
+	for i := 0; i < ml.Count; i++ {
+		if ml.Moves[i] == t.prevPV[ply] {
+			copy(ml.Moves[1:i+1], ml.Moves[:i])
+			ml.Moves[0] = t.prevPV[ply]
+			t.followPV = true
+			return
+		}
+	}
+}
+
+func (t *thread) principalVariation() []moves.Move {
+	return append([]moves.Move(nil), t.pvTable[0][:t.pvLength[0]]...)
+}
+
+// PrincipalVariation is the line found by the main thread's last search
+func PrincipalVariation() []moves.Move {
+	return threads[0].principalVariation()
+}
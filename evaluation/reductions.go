@@ -0,0 +1,40 @@
+package evaluation
+
+import "math"
+
+// late move reduction and pruning settings
+var (
+	LMRMinDepth = 3
+	LMRMinMoves = 3
+	LMPMaxDepth = 3
+)
+
+// lmrTable holds the base reduction for a depth and move number, growing with
+// the log of both
+var lmrTable [MaxDepth + 1][218]int
+
+func init() {
+	for depth := 1; depth <= MaxDepth; depth++ {
+		for i := 1; i < len(lmrTable[depth]); i++ {
+			lmrTable[depth][i] = int(0.75 + math.Log(float64(depth))*math.Log(float64(i))/2.25)
+		}
+	}
+}
+
+// reduction is how many plies to take off a late quiet move. moves the
+// history likes are reduced less and ones it dislikes more, and pv nodes are
+// reduced a ply less. the result always leaves at least one ply to search
+func reduction(depth, moveNumber, moveHistory int, pvNode bool) int {
+	r := lmrTable[min(depth, MaxDepth)][moveNumber]
+	r -= moveHistory / (MaxHistoryScore / 2)
+	if pvNode {
+		r--
+	}
+	return max(0, min(r, depth-2))
+}
+
+// lmpThreshold is the number of quiet moves searched at depth before the
+// rest are pruned
+func lmpThreshold(depth int) int {
+	return 3 + depth*depth
+}
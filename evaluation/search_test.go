@@ -0,0 +1,37 @@
+package evaluation
+
+import (
+	"bot/board"
+	"context"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	board.InitMagicBitboards()
+	board.InitZobrist()
+	os.Exit(m.Run())
+}
+
+// every move of the pv was played in the search, so the deepest ply reached
+// is at least as long as the pv
+func TestSelDepth(t *testing.T) {
+	for _, fen := range []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"6k1/5ppp/8/8/8/8/5PPP/3R2K1 w - - 0 1",
+	} {
+		var b board.Board
+		if err := b.FromFen(fen); err != nil {
+			t.Fatal(err)
+		}
+		ClearTT()
+		ClearHistory()
+
+		SearchContext(context.Background(), &b, Limits{Depth: 6}, func(r Result) {
+			if r.SelDepth < len(r.PV) || r.SelDepth < 1 {
+				t.Errorf("%s depth %d: seldepth %d with a pv of %d moves", fen, r.Depth, r.SelDepth, len(r.PV))
+			}
+		})
+	}
+}
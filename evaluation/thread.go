@@ -0,0 +1,75 @@
+package evaluation
+
+import (
+	"bot/board"
+	"bot/moves"
+	"sync/atomic"
+)
+
+const MaxThreads = 256
+
+// thread is everything one search thread writes to. lazy smp runs several of
+// them on the same position at once, sharing nothing but the transposition
+// table, and lets the table spread what each one finds to the others
+type thread struct {
+	id    int
+	board *board.Board
+	nodes atomic.Uint64
+
+	selDepth  int
+	rootDepth int
+
+	// triangular pv table, row ply holds the best line found from that ply on
+	pvTable  [MaxDepth + 2][MaxDepth + 2]moves.Move
+	pvLength [MaxDepth + 2]int
+
+	// the pv of the last finished iteration is searched first in the next
+	// one, followPV is true while we are still walking down that line
+	prevPV   []moves.Move
+	followPV bool
+
+	// two quiet moves per ply that caused a beta cutoff in a sibling node
+	killers [MaxDepth + 2][2]moves.Move
+	// butterfly history indexed by side to move, from and to square
+	history [2][64][64]int
+	// the quiet reply that refuted a move, indexed by the piece that moved
+	// and where it went
+	counterMoves [12][64]moves.Move
+
+	// the move a singular verification search at that ply leaves out
+	excluded   [MaxDepth + 2]moves.Move
+	extensions ExtensionStats
+}
+
+var threads = []*thread{{id: 0}}
+
+// SetThreads changes how many threads search in parallel. it must not be
+// called while a search is running
+func SetThreads(n int) {
+	n = min(max(n, 1), MaxThreads)
+	for len(threads) < n {
+		threads = append(threads, &thread{id: len(threads)})
+	}
+	threads = threads[:n]
+}
+
+func Threads() int {
+	return len(threads)
+}
+
+// prepare gets a thread ready to search a fresh copy of b
+func (t *thread) prepare(b *board.Board) {
+	t.board = b.Copy()
+	t.nodes.Store(0)
+	t.prevPV = nil
+	t.extensions = ExtensionStats{}
+	t.ageHistory()
+}
+
+func totalNodes() uint64 {
+	var nodes uint64
+	for _, t := range threads {
+		nodes += t.nodes.Load()
+	}
+	return nodes
+}
@@ -0,0 +1,41 @@
+package evaluation
+
+import "time"
+
+const MaxDepth = 64
+
+// MoveOverhead is kept back from every budget to cover gui and pipe lag
+var MoveOverhead = 30 * time.Millisecond
+
+// Clock is the time control sent by the gui, zero fields are "not set"
+type Clock struct {
+	WTime, BTime time.Duration
+	WInc, BInc   time.Duration
+	MovesToGo    int
+}
+
+func (c Clock) IsSet() bool {
+	return c.WTime > 0 || c.BTime > 0
+}
+
+// Budget splits the clock of the side to move into a soft limit, after which
+// no new iteration is started, and a hard limit where the search is aborted
+func (c Clock) Budget(white bool) (soft, hard time.Duration) {
+	remaining, inc := c.BTime, c.BInc
+	if white {
+		remaining, inc = c.WTime, c.WInc
+	}
+
+	movesToGo := c.MovesToGo
+	if movesToGo <= 0 {
+		movesToGo = 30 // sudden death, assume the game lasts about 30 more moves
+	}
+
+	usable := max(remaining-MoveOverhead, time.Millisecond)
+
+	soft = usable/time.Duration(movesToGo) + inc*3/4
+	hard = min(soft*3, usable*3/4)
+	soft = min(soft, hard)
+
+	return soft, hard
+}
@@ -0,0 +1,172 @@
+package evaluation
+
+import (
+	"bot/board"
+	"bot/moves"
+	"sync/atomic"
+	"unsafe"
+)
+
+type EntryFlag uint8
+
+const (
+	empty EntryFlag = iota
+	Exact
+	Alpha
+	Beta
+)
+
+const (
+	DefaultHashMB = 16
+	MaxHashMB     = 4096
+)
+
+type TTEntry struct {
+	Depth int
+	Score int
+	Flag  EntryFlag // exact, alpha, beta
+	Move  moves.Move
+}
+
+// ttSlot is one entry packed into two words, the data and the full hash
+// xored with it. threads read and write slots without locking, and a slot
+// torn by two writers at once no longer xors back to its hash, so probes
+// simply see a miss instead of another position's data
+type ttSlot struct {
+	key  atomic.Uint64
+	data atomic.Uint64
+}
+
+// data layout: move 0-15, score 16-31, depth 32-39, flag 40-47, generation 48-55
+func packSlot(move moves.Move, score, depth int, flag EntryFlag, generation uint8) uint64 {
+	return uint64(move) | uint64(uint16(int16(score)))<<16 | uint64(uint8(int8(depth)))<<32 |
+		uint64(flag)<<40 | uint64(generation)<<48
+}
+
+func slotMove(data uint64) moves.Move  { return moves.Move(data) }
+func slotScore(data uint64) int        { return int(int16(data >> 16)) }
+func slotDepth(data uint64) int        { return int(int8(data >> 32)) }
+func slotFlag(data uint64) EntryFlag   { return EntryFlag(data >> 40) }
+func slotGeneration(data uint64) uint8 { return uint8(data >> 48) }
+
+// slot 0 keeps the deepest entry of the current search, slot 1 is always
+// overwritten so fresh shallow results still get stored somewhere. an entry
+// of the current search replaced in slot 0 moves down to slot 1
+type ttBucket struct {
+	slots [2]ttSlot
+}
+
+type transpositionTable struct {
+	buckets    []ttBucket
+	mask       uint64
+	generation uint8
+}
+
+var TranspositionTable = newTranspositionTable(DefaultHashMB)
+
+func newTranspositionTable(mb int) *transpositionTable {
+	mb = min(max(mb, 1), MaxHashMB)
+	count := uint64(mb) * 1024 * 1024 / uint64(unsafe.Sizeof(ttBucket{}))
+
+	// round down to a power of two so the index is a mask instead of a modulo
+	size := uint64(1)
+	for size*2 <= count {
+		size *= 2
+	}
+
+	return &transpositionTable{
+		buckets: make([]ttBucket, size),
+		mask:    size - 1,
+	}
+}
+
+// ResizeTT reallocates the table to roughly mb megabytes, dropping its contents
+func ResizeTT(mb int) {
+	TranspositionTable = newTranspositionTable(mb)
+}
+
+func ClearTT() {
+	clear(TranspositionTable.buckets)
+	TranspositionTable.generation = 0
+}
+
+// NewSearchTT ages every entry so that results from earlier searches are the
+// first to be replaced
+func NewSearchTT() {
+	TranspositionTable.generation++
+}
+
+func StoreTT(hash board.Bitboard, entry TTEntry) {
+	tt := TranspositionTable
+	bucket := &tt.buckets[uint64(hash)&tt.mask]
+
+	slot := &bucket.slots[0]
+	data := slot.data.Load()
+	key := slot.key.Load()
+	same := key^data == uint64(hash)
+	if !same && slotGeneration(data) == tt.generation && slotFlag(data) != empty && entry.Depth < slotDepth(data) {
+		slot = &bucket.slots[1]
+		data = slot.data.Load()
+		same = slot.key.Load()^data == uint64(hash)
+	} else if !same && slotFlag(data) != empty && slotGeneration(data) == tt.generation {
+		// an entry of this search pushed out of slot 0 moves down instead of
+		// being lost, one from an earlier search is just overwritten
+		bucket.slots[1].key.Store(key)
+		bucket.slots[1].data.Store(data)
+	}
+
+	// a fail low has no best move, keep the one we already knew about
+	if entry.Move == 0 && same {
+		entry.Move = slotMove(data)
+	}
+
+	data = packSlot(entry.Move, entry.Score, entry.Depth, entry.Flag, tt.generation)
+	slot.key.Store(uint64(hash) ^ data)
+	slot.data.Store(data)
+}
+
+// ProbeTT returns the entry for hash whatever its depth, the caller decides if
+// the score is deep enough to use. the move is useful for ordering either way
+func ProbeTT(hash board.Bitboard) (TTEntry, bool) {
+	tt := TranspositionTable
+	bucket := &tt.buckets[uint64(hash)&tt.mask]
+
+	for i := range bucket.slots {
+		slot := &bucket.slots[i]
+		data := slot.data.Load()
+		if slot.key.Load()^data == uint64(hash) && slotFlag(data) != empty {
+			return TTEntry{
+				Depth: slotDepth(data),
+				Score: slotScore(data),
+				Flag:  slotFlag(data),
+				Move:  slotMove(data),
+			}, true
+		}
+	}
+	return TTEntry{}, false
+}
+
+func LookupTT(hash board.Bitboard, depth int) (TTEntry, bool) {
+	entry, ok := ProbeTT(hash)
+	if ok && entry.Depth >= depth {
+		return entry, true
+	}
+	return TTEntry{}, false
+}
+
+// Hashfull samples the first thousand buckets and returns how many slots out
+// of a thousand hold an entry from the current search
+func Hashfull() int {
+	tt := TranspositionTable
+	sample := min(len(tt.buckets), 1000)
+	used := 0
+	for i := 0; i < sample; i++ {
+		for j := range tt.buckets[i].slots {
+			data := tt.buckets[i].slots[j].data.Load()
+			if slotFlag(data) != empty && slotGeneration(data) == tt.generation {
+				used++
+			}
+		}
+	}
+	return used * 1000 / (sample * len(ttBucket{}.slots))
+}
@@ -0,0 +1,55 @@
+package evaluation
+
+import (
+	"bot/board"
+	"testing"
+)
+
+func TestTTReplacement(t *testing.T) {
+	ResizeTT(1)
+	defer ResizeTT(DefaultHashMB)
+
+	// every hash lands in the same bucket
+	size := board.Bitboard(len(TranspositionTable.buckets))
+	a, b, c, d := 5+size, 5+2*size, 5+3*size, 5+4*size
+
+	hits := func(want map[board.Bitboard]bool) {
+		t.Helper()
+		for hash, hit := range want {
+			if _, ok := ProbeTT(hash); ok != hit {
+				t.Errorf("probe %d: hit = %v, want %v", hash/size, ok, hit)
+			}
+		}
+	}
+
+	// a shallower entry of the same search leaves the deep one in slot 0
+	StoreTT(a, TTEntry{Depth: 5, Flag: Exact})
+	StoreTT(b, TTEntry{Depth: 3, Flag: Exact})
+	hits(map[board.Bitboard]bool{a: true, b: true})
+
+	// a deeper one takes slot 0 and the old entry moves down over slot 1
+	StoreTT(c, TTEntry{Depth: 7, Flag: Exact})
+	hits(map[board.Bitboard]bool{a: true, b: false, c: true})
+
+	// after a new search starts anything can take slot 0, and the entry it
+	// replaces is too old to be worth pushing out slot 1
+	NewSearchTT()
+	StoreTT(d, TTEntry{Depth: 1, Flag: Beta})
+	hits(map[board.Bitboard]bool{a: true, c: false, d: true})
+	if entry, _ := ProbeTT(d); entry.Depth != 1 || entry.Flag != Beta {
+		t.Errorf("d = %+v", entry)
+	}
+}
+
+func TestTTKeepsMove(t *testing.T) {
+	ResizeTT(1)
+	defer ResizeTT(DefaultHashMB)
+
+	StoreTT(42, TTEntry{Depth: 3, Score: 10, Flag: Beta, Move: 1234})
+	StoreTT(42, TTEntry{Depth: 4, Score: -20, Flag: Alpha})
+
+	entry, ok := ProbeTT(42)
+	if !ok || entry.Move != 1234 || entry.Depth != 4 || entry.Score != -20 || entry.Flag != Alpha {
+		t.Errorf("got %+v, %v", entry, ok)
+	}
+}
@@ -3,189 +3,133 @@ package main
 import (
 	"bot/board"
 	"bot/evaluation"
-	"bot/moves"
+	"bot/pgn"
+	"bot/uci"
+	"bot/xboard"
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
 	"runtime/pprof"
-	"sort"
 	"strings"
 	"time"
 )
 
-func Perft(b *board.Board, depth int) uint64 {
-	if depth == 0 {
-		return 1
-	}
-
-	moves := b.Moves(false)
-
-	if depth == 1 {
-		return uint64(moves.Count)
-	}
-
-	var nodes uint64
-	for i := 0; i < moves.Count; i++ {
-		move := moves.Moves[i]
-		b.PlayMove(move)
-		nodes += Perft(b, depth-1)
-		b.UndoMove(move)
-	}
-
-	return nodes
+type Protocol interface {
+	Handle(line string) bool
+	Close()
 }
 
-func PerftDivide(b *board.Board, depth int) uint64 {
-	Moves := b.Moves(false)
-	var totalNodes uint64
-
-	type MoveResult struct {
-		move  moves.Move
-		nodes uint64
-		str   string
-	}
-	results := make([]MoveResult, 0, Moves.Count)
-
-	fmt.Printf("\nPerft Divide (depth %d):\n", depth)
-	fmt.Println("------------------------")
-
-	for i := 0; i < Moves.Count; i++ {
-		move := Moves.Moves[i]
-		b.PlayMove(move)
-
-		var nodes uint64
-		if depth == 1 {
-			nodes = 1
-		} else {
-			nodes = Perft(b, depth-1)
+func main() {
+	cpuprofile := flag.String("cpuprofile", "", "write a cpu profile to this file")
+	perft := flag.Int("perft", 0, "run perft divide to this depth and exit")
+	fen := flag.String("fen", board.StartFen, "position for -perft")
+	checkHash := flag.Bool("checkhash", false, "with -perft, verify the incremental hash at every node")
+	selfplay := flag.String("selfplay", "", "play a game against itself from -fen and append it to this pgn file")
+	depth := flag.Int("depth", 7, "search depth for -selfplay")
+	flag.Parse()
+
+	if *cpuprofile != "" {
+		f, err := os.Create(*cpuprofile)
+		if err != nil {
+			panic(err)
 		}
+		defer f.Close()
 
-		b.UndoMove(move)
-
-		moveStr := move.MoveToString()
-		results = append(results, MoveResult{move, nodes, moveStr})
-		totalNodes += nodes
+		pprof.StartCPUProfile(f)
+		defer pprof.StopCPUProfile()
 	}
 
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].str < results[j].str
-	})
+	board.InitMagicBitboards()
+	board.InitZobrist()
 
-	for _, result := range results {
-		fmt.Printf("%s: %d\n", result.str, result.nodes)
+	if *perft > 0 {
+		var b board.Board
+		if err := b.FromFen(*fen); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if *checkHash {
+			nodes, err := board.PerftCheckHash(&b, *perft)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			fmt.Printf("hash consistent over %d nodes\n", nodes)
+			return
+		}
+		board.PerftDivide(&b, *perft)
+		return
 	}
 
-	fmt.Println("------------------------")
-	fmt.Printf("Total: %d\n", totalNodes)
-
-	return totalNodes
-}
-
-func StringToMove(moveStr string) moves.Move {
-	files := "abcdefgh"
-	ranks := "87654321"
-
-	fromFile := strings.IndexByte(files, moveStr[0])
-	fromRank := strings.IndexByte(ranks, moveStr[1])
-	from := int8(fromRank*8 + fromFile)
-
-	toFile := strings.IndexByte(files, moveStr[2])
-	toRank := strings.IndexByte(ranks, moveStr[3])
-	to := int8(toRank*8 + toFile)
-
-	if len(moveStr) == 5 {
-		switch moveStr[4] {
-		case 'q':
-			return moves.NewMove(from, to, moves.FlagPromotionKnight)
-		case 'r':
-			return moves.NewMove(from, to, moves.FlagPromotionBishop)
-		case 'b':
-			return moves.NewMove(from, to, moves.FlagPromotionRook)
-		case 'n':
-			return moves.NewMove(from, to, moves.FlagPromotionQueen)
+	if *selfplay != "" {
+		if err := selfPlay(*selfplay, *fen, *depth); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
 		}
+		return
 	}
 
-	return moves.NewMove(from, to, moves.FlagNone)
-}
-
-func timer(name string) func() {
-	start := time.Now()
-	return func() {
-		fmt.Printf("%s took %v\n", name, time.Since(start))
+	reader := bufio.NewScanner(os.Stdin)
+	if !reader.Scan() {
+		return
 	}
-}
-
-func main() {
-	defer timer("main")()
 
-	f, err := os.Create("cpu.prof")
-	if err != nil {
-		panic(err)
+	// the first command decides which protocol the gui is speaking
+	first := reader.Text()
+	var engine Protocol
+	if strings.TrimSpace(first) == "xboard" {
+		engine = xboard.NewEngine(os.Stdout)
+	} else {
+		engine = uci.NewEngine(os.Stdout)
 	}
-	defer f.Close()
-
-	pprof.StartCPUProfile(f)
-	defer pprof.StopCPUProfile()
-	board.InitMagicBitboards()
-	reader := bufio.NewScanner(os.Stdin)
+	defer engine.Close()
 
-	b := board.Board{
-		UndoCount: 0,
+	if !engine.Handle(first) {
+		return
 	}
-	b.FromFen("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
-
-	board.InitZobrist()
-	fmt.Println(board.CalculateHash(&b))
-
 	for reader.Scan() {
-		line := strings.TrimSpace(reader.Text())
-
-		switch {
-		case line == "start":
-			move := evaluation.FindBestMove(&b, 7)
-			fmt.Println(move.MoveToString())
-			b.PlayMove(move)
-		case strings.HasPrefix(line, "go"):
-			b.PlayMove(StringToMove(strings.Split(line, " ")[1]))
-			move := evaluation.FindBestMove(&b, 7)
-			moveStr := move.MoveToString()
-			b.PlayMove(move)
-
-			fmt.Println(moveStr)
-		case line == "playurself":
-			for {
-				move := evaluation.FindBestMove(&b, 7)
-				fmt.Println(move.MoveToString())
-				b.PlayMove(move)
-			}
-		case line == "quit":
+		if !engine.Handle(reader.Text()) {
 			return
-		case line == "test":
-			fmt.Println(evaluation.Evaluate(&b))
-			undo := moves.NewMove(1, 16, moves.FlagNone)
-			b.PlayMove(undo)
-			fmt.Println(evaluation.Evaluate(&b))
-			b.UndoMove(undo)
-			undo = moves.NewMove(1, 18, moves.FlagNone)
-			b.PlayMove(undo)
-			fmt.Println(evaluation.Evaluate(&b))
-			b.UndoMove(undo)
 		}
 	}
+}
+
+// selfPlay has the engine play itself, printing the moves as it goes, and
+// appends the finished game to the pgn file at path
+func selfPlay(path, fen string, depth int) error {
+	var b board.Board
+	if err := b.FromFen(fen); err != nil {
+		return err
+	}
 
-	fmt.Fprintln(os.Stderr, "Loop exited!")
-	if err := reader.Err(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-	} else {
-		fmt.Fprintln(os.Stderr, "EOF received")
+	game := pgn.NewGame(fen)
+	game.SetTag("Event", "self-play")
+	game.SetTag("Date", time.Now().Format("2006.01.02"))
+	game.SetTag("White", evaluation.EngineName)
+	game.SetTag("Black", evaluation.EngineName)
+	if b.Chess960 {
+		game.SetTag("Variant", "Chess960")
 	}
 
-	//fmt.Println(b.Mailbox)
-	//fmt.Println(Perft(&b, 6))
+	node := game.Root
+	for {
+		if result, reason := b.Outcome(); result != "" {
+			game.Result = result
+			node.Comment = reason
+			break
+		}
+
+		result := evaluation.SearchLimits(&b, evaluation.Limits{Depth: depth})
+		node = node.AddMove(&b, result.Move)
+		fmt.Println(node.SAN)
+	}
+	fmt.Println(game.Result)
 
-	//fmt.Println(MoveToString(moves.NewMove(51, 59, moves.FlagPromotionKnight), &b))
-	//b.PlayMove(move)
-	//b.DebugPrint()
-	//evaluation.BestMove(&b)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return pgn.Write(f, game)
 }
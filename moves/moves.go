@@ -60,11 +60,11 @@ func (m Move) IsCastling() bool {
 
 func (m Move) IsPromotion() bool {
 	flags := m.Flags()
-	return flags >= FlagPromotionKnight && flags <= FlagPromotionQueen
+	return flags >= FlagPromotionQueen && flags <= FlagPromotionKnight
 }
 
 func (m Move) PromotionPiece() uint8 {
-	//0=none, 1=knight, 2=bishop, 3=rook, 4=queen
+	// 0=none, 1=queen, 2=rook, 3=bishop, 4=knight, the same as the piece index
 	if !m.IsPromotion() {
 		return 0
 	}
@@ -95,6 +95,21 @@ func (m Move) MoveToString() string {
 	return from + to + promotion
 }
 
+// MoveToUCI is MoveToString except castling is written as the king's
+// destination square instead of king-takes-rook, which is what guis expect
+func (m Move) MoveToUCI() string {
+	if !m.IsCastling() {
+		return m.MoveToString()
+	}
+
+	rank := m.From() / 8
+	to := rank*8 + 6
+	if m.To() < m.From() {
+		to = rank*8 + 2
+	}
+	return NewMove(m.From(), to, FlagNone).MoveToString()
+}
+
 func (ml *MoveList) Add(move Move) {
 	ml.Moves[ml.Count] = move
 	ml.Count++
@@ -0,0 +1,145 @@
+// Package pgn reads and writes games in Portable Game Notation. movetext is
+// replayed on a board.Board as it is read, so every move in a game tree is
+// known to be legal
+package pgn
+
+import (
+	"bot/board"
+	"bot/moves"
+	"fmt"
+	"strings"
+)
+
+type Tag struct {
+	Name, Value string
+}
+
+// Node is a move in the game tree. the first child continues the line, any
+// others are variations on it
+type Node struct {
+	Move    moves.Move
+	SAN     string
+	NAGs    []int
+	Comment string
+
+	Parent   *Node
+	Children []*Node
+}
+
+type Game struct {
+	Tags []Tag
+
+	// Root holds no move, only the position before the first one and any
+	// comment that comes before the movetext
+	Root   *Node
+	Result string // 1-0, 0-1, 1/2-1/2 or *
+}
+
+// NewGame starts a game from fen, or the standard position when fen is empty
+func NewGame(fen string) *Game {
+	g := &Game{Root: &Node{}, Result: "*"}
+	if fen != "" && fen != board.StartFen {
+		g.SetTag("SetUp", "1")
+		g.SetTag("FEN", fen)
+	}
+	return g
+}
+
+func (g *Game) Tag(name string) string {
+	for _, tag := range g.Tags {
+		if tag.Name == name {
+			return tag.Value
+		}
+	}
+	return ""
+}
+
+func (g *Game) SetTag(name, value string) {
+	for i := range g.Tags {
+		if g.Tags[i].Name == name {
+			g.Tags[i].Value = value
+			return
+		}
+	}
+	g.Tags = append(g.Tags, Tag{name, value})
+}
+
+// StartBoard is the position the game starts from, the FEN tag if there is
+// one
+func (g *Game) StartBoard() (*board.Board, error) {
+	fen := board.StartFen
+	if f := g.Tag("FEN"); f != "" {
+		fen = f
+	}
+
+	b := &board.Board{}
+	if err := b.FromFen(fen); err != nil {
+		return nil, err
+	}
+	// a chess960 game can start from the normal position, only the tag
+	// tells it apart then
+	if strings.EqualFold(g.Tag("Variant"), "chess960") {
+		b.Chess960 = true
+	}
+	return b, nil
+}
+
+// BoardAt replays the moves leading to n
+func (g *Game) BoardAt(n *Node) (*board.Board, error) {
+	b, err := g.StartBoard()
+	if err != nil {
+		return nil, err
+	}
+
+	var path []moves.Move
+	for ; n != nil && n.Parent != nil; n = n.Parent {
+		path = append(path, n.Move)
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		b.PlayMove(path[i])
+	}
+	return b, nil
+}
+
+// MainLine follows the first child from the root to the end of the game
+func (g *Game) MainLine() []*Node {
+	var line []*Node
+	for n := g.Root; len(n.Children) > 0; {
+		n = n.Children[0]
+		line = append(line, n)
+	}
+	return line
+}
+
+// AddMove adds move after n and plays it on b, which has to be the position
+// at n. a move that is already a child is reused instead of added twice
+func (n *Node) AddMove(b *board.Board, move moves.Move) *Node {
+	for _, child := range n.Children {
+		if child.Move == move {
+			b.PlayMove(move)
+			return child
+		}
+	}
+
+	child := &Node{Move: move, SAN: b.MoveToSAN(move), Parent: n}
+	n.Children = append(n.Children, child)
+	b.PlayMove(move)
+	return child
+}
+
+func validResult(s string) bool {
+	return s == "1-0" || s == "0-1" || s == "1/2-1/2" || s == "*"
+}
+
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("pgn: line %d: %v", e.Line, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,150 @@
+package pgn
+
+import (
+	"bot/board"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	board.InitMagicBitboards()
+	board.InitZobrist()
+	os.Exit(m.Run())
+}
+
+const roundTripPGN = `% written by hand, escape lines are skipped
+[Event "round trip"]
+[Site "?"]
+[Date "2024.01.01"]
+[Round "1"]
+[White "A \"quoted\" name"]
+[Black "B"]
+[Result "1-0"]
+[SetUp "1"]
+[FEN "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2"]
+
+{Open game} 2. Nf3 Nc6 3. Bb5!? ; the Spanish
+% Bxf7 is not a move here
+a6 (3... Nf6 4. O-O (4. d3 $1 {quiet}) 4... Nxe4 $6) 4. Ba4 Nf6
+5. O-O?! Be7 $10 1-0
+`
+
+func readOne(t *testing.T, s string) *Game {
+	t.Helper()
+	games, err := ReadAll(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if len(games) != 1 {
+		t.Fatalf("read %d games, want 1", len(games))
+	}
+	return games[0]
+}
+
+// child follows the path of SANs from n, failing if any is missing
+func child(t *testing.T, n *Node, sans ...string) *Node {
+	t.Helper()
+next:
+	for _, san := range sans {
+		for _, c := range n.Children {
+			if c.SAN == san {
+				n = c
+				continue next
+			}
+		}
+		t.Fatalf("no move %s after %s", san, n.SAN)
+	}
+	return n
+}
+
+func sameTree(t *testing.T, a, b *Node) {
+	t.Helper()
+	if a.Move != b.Move || a.SAN != b.SAN || a.Comment != b.Comment || !reflect.DeepEqual(a.NAGs, b.NAGs) {
+		t.Fatalf("node %s %v {%s} != %s %v {%s}", a.SAN, a.NAGs, a.Comment, b.SAN, b.NAGs, b.Comment)
+	}
+	if len(a.Children) != len(b.Children) {
+		t.Fatalf("%s has %d children, want %d", b.SAN, len(b.Children), len(a.Children))
+	}
+	for i := range a.Children {
+		sameTree(t, a.Children[i], b.Children[i])
+	}
+}
+
+func TestReadMovetext(t *testing.T) {
+	g := readOne(t, roundTripPGN)
+
+	if g.Result != "1-0" || g.Tag("SetUp") != "1" || g.Tag("White") != `A "quoted" name` {
+		t.Errorf("tags not read: %+v result %s", g.Tags, g.Result)
+	}
+	if g.Root.Comment != "Open game" {
+		t.Errorf("root comment = %q", g.Root.Comment)
+	}
+
+	var main []string
+	for _, n := range g.MainLine() {
+		main = append(main, n.SAN)
+	}
+	if want := "Nf3 Nc6 Bb5 a6 Ba4 Nf6 O-O Be7"; strings.Join(main, " ") != want {
+		t.Errorf("main line = %v, want %s", main, want)
+	}
+
+	bb5 := child(t, g.Root, "Nf3", "Nc6", "Bb5")
+	if !reflect.DeepEqual(bb5.NAGs, []int{5}) || bb5.Comment != "the Spanish" {
+		t.Errorf("Bb5 NAGs %v comment %q", bb5.NAGs, bb5.Comment)
+	}
+	if len(bb5.Children) != 2 || bb5.Children[1].SAN != "Nf6" {
+		t.Fatalf("3... Nf6 is not a variation on 3... a6")
+	}
+
+	// the nested variation is on 4. O-O, and the line after it goes on from
+	// the move it replaced
+	castle := child(t, bb5, "Nf6", "O-O")
+	if len(castle.Parent.Children) != 2 {
+		t.Fatalf("4. d3 is not a variation on 4. O-O")
+	}
+	d3 := castle.Parent.Children[1]
+	if d3.SAN != "d3" || !reflect.DeepEqual(d3.NAGs, []int{1}) || d3.Comment != "quiet" {
+		t.Errorf("d3 = %s %v %q", d3.SAN, d3.NAGs, d3.Comment)
+	}
+	if nxe4 := child(t, castle, "Nxe4"); !reflect.DeepEqual(nxe4.NAGs, []int{6}) {
+		t.Errorf("Nxe4 NAGs %v", nxe4.NAGs)
+	}
+
+	last := child(t, bb5, "a6", "Ba4", "Nf6", "O-O", "Be7")
+	if !reflect.DeepEqual(last.Parent.NAGs, []int{6}) || !reflect.DeepEqual(last.NAGs, []int{10}) {
+		t.Errorf("O-O?! Be7 $10 NAGs %v %v", last.Parent.NAGs, last.NAGs)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	g := readOne(t, roundTripPGN)
+	written := g.String()
+	again := readOne(t, written)
+
+	if !reflect.DeepEqual(g.Tags, again.Tags) || g.Result != again.Result {
+		t.Errorf("tags %v %s, read back %v %s", g.Tags, g.Result, again.Tags, again.Result)
+	}
+	sameTree(t, g.Root, again.Root)
+
+	if s := again.String(); s != written {
+		t.Errorf("second write differs:\n%s\nfirst:\n%s", s, written)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	for _, s := range []string{
+		"1. e4 (1. d4 *",
+		"1. e4 e5) *",
+		"(1. e4) *",
+		"1. e5 *",
+		"1. e4 e5?!? *",
+		"1. e4 { never closed",
+		"1. e4 (1. d4 1-0) *",
+	} {
+		if _, err := ReadAll(strings.NewReader(s)); err == nil {
+			t.Errorf("%q: no error", s)
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package pgn
+
+import (
+	"bot/board"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokSymbol tokenKind = iota
+	tokString
+	tokComment
+	tokNAG
+	tokOpenTag
+	tokCloseTag
+	tokOpenVariation
+	tokCloseVariation
+	tokPeriod
+	tokResult
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+// suffix annotations and the NAGs they stand for
+var suffixNAGs = map[string]int{"!": 1, "?": 2, "!!": 3, "??": 4, "!?": 5, "?!": 6}
+
+// Reader reads games one at a time from a PGN stream
+type Reader struct {
+	r      *bufio.Reader
+	line   int
+	peeked *token
+
+	// the last character read was a newline, % only starts an escape at the
+	// beginning of a line
+	lineStart bool
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r), line: 1, lineStart: true}
+}
+
+// ReadAll reads every game in r
+func ReadAll(r io.Reader) ([]*Game, error) {
+	pr := NewReader(r)
+	var games []*Game
+	for {
+		g, err := pr.Next()
+		if err == io.EOF {
+			return games, nil
+		}
+		if err != nil {
+			return games, err
+		}
+		games = append(games, g)
+	}
+}
+
+// Next reads the next game, io.EOF once there are none left
+func (pr *Reader) Next() (*Game, error) {
+	tok, err := pr.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Game{Root: &Node{}, Result: "*"}
+	for tok.kind == tokOpenTag {
+		if err := pr.readTag(g); err != nil {
+			return nil, err
+		}
+		if tok, err = pr.peek(); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if r := g.Tag("Result"); validResult(r) {
+		g.Result = r
+	}
+
+	b, err := g.StartBoard()
+	if err != nil {
+		return nil, &ParseError{tok.line, err}
+	}
+	if err := pr.readMovetext(g, b); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (pr *Reader) readTag(g *Game) error {
+	pr.next() // [
+	name, err := pr.expect(tokSymbol, "tag name")
+	if err != nil {
+		return err
+	}
+	value, err := pr.expect(tokString, "tag value")
+	if err != nil {
+		return err
+	}
+	if _, err := pr.expect(tokCloseTag, "]"); err != nil {
+		return err
+	}
+	g.SetTag(name.text, value.text)
+	return nil
+}
+
+// readMovetext replays the moves on b, building the tree as it goes. a
+// variation replaces the move before it, so on "(" that move is taken back
+// and on ")" the board is walked back to it and it is played again
+func (pr *Reader) readMovetext(g *Game, b *board.Board) error {
+	cur := g.Root
+	var branches []*Node
+
+	for {
+		tok, err := pr.peek()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if tok.kind == tokOpenTag {
+			// the next game's tags, this one had no result
+			break
+		}
+		pr.next()
+
+		switch tok.kind {
+		case tokResult:
+			if len(branches) > 0 {
+				return &ParseError{tok.line, errors.New("result inside a variation")}
+			}
+			g.Result = tok.text
+			return nil
+
+		case tokComment:
+			if cur.Comment != "" {
+				cur.Comment += " "
+			}
+			cur.Comment += tok.text
+
+		case tokNAG:
+			n, err := strconv.Atoi(tok.text)
+			if err != nil || cur == g.Root {
+				return &ParseError{tok.line, fmt.Errorf("misplaced NAG $%s", tok.text)}
+			}
+			cur.NAGs = append(cur.NAGs, n)
+
+		case tokPeriod:
+
+		case tokOpenVariation:
+			if cur == g.Root {
+				return &ParseError{tok.line, errors.New("variation before any move")}
+			}
+			branches = append(branches, cur)
+			b.UndoMove(cur.Move)
+			cur = cur.Parent
+
+		case tokCloseVariation:
+			if len(branches) == 0 {
+				return &ParseError{tok.line, errors.New("unmatched )")}
+			}
+			branch := branches[len(branches)-1]
+			branches = branches[:len(branches)-1]
+			for cur != branch.Parent {
+				b.UndoMove(cur.Move)
+				cur = cur.Parent
+			}
+			b.PlayMove(branch.Move)
+			cur = branch
+
+		case tokSymbol:
+			// move numbers are only there for people
+			if _, err := strconv.Atoi(tok.text); err == nil {
+				continue
+			}
+
+			san, suffix := splitSuffix(tok.text)
+			move, err := b.ParseSAN(san)
+			if err != nil {
+				return &ParseError{tok.line, err}
+			}
+			cur = cur.AddMove(b, move)
+			if suffix != "" {
+				nag, ok := suffixNAGs[suffix]
+				if !ok {
+					return &ParseError{tok.line, fmt.Errorf("unknown annotation %s", suffix)}
+				}
+				cur.NAGs = append(cur.NAGs, nag)
+			}
+
+		default:
+			return &ParseError{tok.line, fmt.Errorf("unexpected %q in movetext", tok.text)}
+		}
+	}
+
+	if len(branches) > 0 {
+		return &ParseError{pr.line, errors.New("unterminated variation")}
+	}
+	return nil
+}
+
+func splitSuffix(s string) (san, suffix string) {
+	i := len(s)
+	for i > 0 && (s[i-1] == '!' || s[i-1] == '?') {
+		i--
+	}
+	return s[:i], s[i:]
+}
+
+func (pr *Reader) expect(kind tokenKind, what string) (token, error) {
+	tok, err := pr.next()
+	if err == io.EOF {
+		return tok, &ParseError{pr.line, fmt.Errorf("expected %s, got end of file", what)}
+	}
+	if err != nil {
+		return tok, err
+	}
+	if tok.kind != kind {
+		return tok, &ParseError{tok.line, fmt.Errorf("expected %s, got %q", what, tok.text)}
+	}
+	return tok, nil
+}
+
+func (pr *Reader) peek() (token, error) {
+	if pr.peeked == nil {
+		tok, err := pr.scan()
+		if err != nil {
+			return tok, err
+		}
+		pr.peeked = &tok
+	}
+	return *pr.peeked, nil
+}
+
+func (pr *Reader) next() (token, error) {
+	if pr.peeked != nil {
+		tok := *pr.peeked
+		pr.peeked = nil
+		return tok, nil
+	}
+	return pr.scan()
+}
+
+func (pr *Reader) read() (rune, error) {
+	ch, _, err := pr.r.ReadRune()
+	if err != nil {
+		return ch, err
+	}
+	if ch == '\n' {
+		pr.line++
+	}
+	pr.lineStart = ch == '\n'
+	return ch, nil
+}
+
+// unread puts back the character just read. it always followed another
+// character on the same line, so we can't be at the start of one
+func (pr *Reader) unread(ch rune) {
+	pr.r.UnreadRune()
+	if ch == '\n' {
+		pr.line--
+	}
+	pr.lineStart = false
+}
+
+func isSymbolChar(ch rune) bool {
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' ||
+		strings.ContainsRune("_+#=:-/!?", ch)
+}
+
+func (pr *Reader) scan() (token, error) {
+	for {
+		atLineStart := pr.lineStart
+		ch, err := pr.read()
+		if err != nil {
+			return token{}, err
+		}
+		line := pr.line
+
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n':
+			continue
+		case ch == '%' && atLineStart:
+			// escape line, skipped up to the newline
+			if _, err := pr.readUntil('\n'); err != nil {
+				return token{}, err
+			}
+			continue
+		}
+
+		switch ch {
+		case '[':
+			return token{tokOpenTag, "[", line}, nil
+		case ']':
+			return token{tokCloseTag, "]", line}, nil
+		case '(':
+			return token{tokOpenVariation, "(", line}, nil
+		case ')':
+			return token{tokCloseVariation, ")", line}, nil
+		case '.':
+			return token{tokPeriod, ".", line}, nil
+		case '*':
+			return token{tokResult, "*", line}, nil
+		case '{':
+			text, err := pr.readUntil('}')
+			if err != nil {
+				return token{}, &ParseError{line, errors.New("unterminated comment")}
+			}
+			return token{tokComment, strings.Join(strings.Fields(text), " "), line}, nil
+		case ';':
+			text, err := pr.readUntil('\n')
+			if err == nil {
+				pr.unread('\n')
+			}
+			return token{tokComment, strings.TrimSpace(text), line}, nil
+		case '"':
+			return pr.scanString(line)
+		case '$':
+			digits := ""
+			for {
+				ch, err := pr.read()
+				if err != nil || ch < '0' || ch > '9' {
+					if err == nil {
+						pr.unread(ch)
+					}
+					break
+				}
+				digits += string(ch)
+			}
+			return token{tokNAG, digits, line}, nil
+		}
+
+		if !isSymbolChar(ch) {
+			return token{}, &ParseError{line, fmt.Errorf("unexpected character %q", ch)}
+		}
+
+		symbol := string(ch)
+		for {
+			ch, err := pr.read()
+			if err != nil {
+				break
+			}
+			if !isSymbolChar(ch) {
+				pr.unread(ch)
+				break
+			}
+			symbol += string(ch)
+		}
+
+		if symbol == "1-0" || symbol == "0-1" || symbol == "1/2-1/2" {
+			return token{tokResult, symbol, line}, nil
+		}
+		return token{tokSymbol, symbol, line}, nil
+	}
+}
+
+func (pr *Reader) scanString(line int) (token, error) {
+	var sb strings.Builder
+	for {
+		ch, err := pr.read()
+		if err != nil || ch == '\n' {
+			return token{}, &ParseError{line, errors.New("unterminated string")}
+		}
+		switch ch {
+		case '"':
+			return token{tokString, sb.String(), line}, nil
+		case '\\':
+			ch, err = pr.read()
+			if err != nil {
+				return token{}, &ParseError{line, errors.New("unterminated string")}
+			}
+		}
+		sb.WriteRune(ch)
+	}
+}
+
+// readUntil reads up to and including end, returning what came before it
+func (pr *Reader) readUntil(end rune) (string, error) {
+	var sb strings.Builder
+	for {
+		ch, err := pr.read()
+		if err != nil {
+			return sb.String(), err
+		}
+		if ch == end {
+			return sb.String(), nil
+		}
+		sb.WriteRune(ch)
+	}
+}
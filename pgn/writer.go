@@ -0,0 +1,163 @@
+package pgn
+
+import (
+	"bot/board"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// the seven tag roster comes first and in this order, "?" if unknown
+var rosterTags = []string{"Event", "Site", "Date", "Round", "White", "Black", "Result"}
+
+const lineWidth = 80
+
+// Write writes g as PGN followed by a blank line, so games can be appended
+// to the same file
+func Write(w io.Writer, g *Game) error {
+	_, err := io.WriteString(w, g.String()+"\n")
+	return err
+}
+
+func (g *Game) String() string {
+	var sb strings.Builder
+
+	for _, name := range rosterTags {
+		value := g.Tag(name)
+		switch {
+		case name == "Result":
+			value = g.Result
+		case value == "":
+			value = "?"
+		}
+		writeTag(&sb, name, value)
+	}
+	for _, tag := range g.Tags {
+		if !isRosterTag(tag.Name) {
+			writeTag(&sb, tag.Name, tag.Value)
+		}
+	}
+	sb.WriteString("\n")
+
+	mw := &movetextWriter{}
+	if g.Root.Comment != "" {
+		mw.word("{" + cleanComment(g.Root.Comment) + "}")
+	}
+
+	// the starting move number and side come from the FEN tag
+	ply := 0
+	if b, err := g.StartBoard(); err == nil {
+		ply = startPly(b)
+	}
+	mw.line(g.Root, ply, true)
+	mw.word(g.Result)
+
+	sb.WriteString(mw.String())
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+func writeTag(sb *strings.Builder, name, value string) {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	fmt.Fprintf(sb, "[%s \"%s\"]\n", name, value)
+}
+
+func isRosterTag(name string) bool {
+	for _, roster := range rosterTags {
+		if name == roster {
+			return true
+		}
+	}
+	return false
+}
+
+func startPly(b *board.Board) int {
+	ply := (max(b.FullMoves, 1) - 1) * 2
+	if !b.Turn {
+		ply++
+	}
+	return ply
+}
+
+// a closing brace would end the comment early
+func cleanComment(s string) string {
+	return strings.ReplaceAll(s, "}", "")
+}
+
+// movetextWriter collects movetext words and wraps them into lines
+type movetextWriter struct {
+	sb      strings.Builder
+	lineLen int
+
+	// an opening bracket waiting to be glued onto the next word
+	prefix string
+}
+
+func (mw *movetextWriter) word(w string) {
+	w, mw.prefix = mw.prefix+w, ""
+
+	if mw.lineLen > 0 && mw.lineLen+1+len(w) > lineWidth {
+		mw.sb.WriteString("\n")
+		mw.lineLen = 0
+	}
+	if mw.lineLen > 0 {
+		mw.sb.WriteString(" ")
+		mw.lineLen++
+	}
+	mw.sb.WriteString(w)
+	mw.lineLen += len(w)
+}
+
+// closeVariation glues the bracket onto the last word written
+func (mw *movetextWriter) closeVariation() {
+	mw.sb.WriteString(")")
+	mw.lineLen++
+}
+
+func (mw *movetextWriter) String() string {
+	return mw.sb.String()
+}
+
+// line writes the moves after n, each main move followed by the variations
+// on it. ply is the ply of the first move, number forces a move number in
+// front of it even when black is to move
+func (mw *movetextWriter) line(n *Node, ply int, number bool) {
+	for len(n.Children) > 0 {
+		main := n.Children[0]
+		number = mw.move(main, ply, number)
+
+		for _, variation := range n.Children[1:] {
+			mw.prefix = "("
+			mw.move(variation, ply, true)
+			mw.line(variation, ply+1, false)
+			mw.closeVariation()
+			number = true
+		}
+
+		n = main
+		ply++
+	}
+}
+
+// move writes a single move with its annotations and reports whether the
+// next move needs its number repeated
+func (mw *movetextWriter) move(n *Node, ply int, number bool) bool {
+	word := n.SAN
+	if ply%2 == 0 {
+		word = strconv.Itoa(ply/2+1) + ". " + word
+	} else if number {
+		word = strconv.Itoa(ply/2+1) + "... " + word
+	}
+	mw.word(word)
+
+	for _, nag := range n.NAGs {
+		mw.word("$" + strconv.Itoa(nag))
+	}
+	if n.Comment != "" {
+		mw.word("{" + cleanComment(n.Comment) + "}")
+		return true
+	}
+	return false
+}
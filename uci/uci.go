@@ -0,0 +1,374 @@
+package uci
+
+import (
+	"bot/board"
+	"bot/evaluation"
+	"bot/moves"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const EngineAuthor = "AAABatteryPowered"
+
+type Engine struct {
+	Board board.Board
+	Depth int
+
+	// Chess960 is the UCI_Chess960 option, castling is then sent as
+	// king-takes-rook. a chess960 FEN turns it on for that game regardless
+	Chess960 bool
+
+	out       io.Writer
+	mu        sync.Mutex
+	done      chan struct{}
+	cancel    context.CancelFunc
+	ponderhit chan struct{}
+}
+
+func NewEngine(out io.Writer) *Engine {
+	e := &Engine{
+		Depth: 7,
+		out:   out,
+	}
+	e.Board.FromFen(board.StartFen)
+	return e
+}
+
+// Handle runs a single command and returns false once the engine should exit
+func (e *Engine) Handle(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return true
+	}
+
+	switch fields[0] {
+	case "uci":
+		e.send("id name %s", evaluation.EngineName)
+		e.send("id author %s", EngineAuthor)
+		e.send("option name Hash type spin default %d min 1 max %d", evaluation.DefaultHashMB, evaluation.MaxHashMB)
+		e.send("option name Clear Hash type button")
+		e.send("option name Threads type spin default 1 min 1 max %d", evaluation.MaxThreads)
+		e.send("option name Ponder type check default false")
+		e.send("option name Depth type spin default 7 min 1 max 64")
+		e.send("option name UCI_Chess960 type check default false")
+		e.send("uciok")
+	case "isready":
+		e.send("readyok")
+	case "ucinewgame":
+		e.stop()
+		e.Board.FromFen(board.StartFen)
+		evaluation.ClearTT()
+		evaluation.ClearHistory()
+	case "position":
+		e.stop()
+		if err := e.position(fields[1:]); err != nil {
+			e.send("info string %v", err)
+		}
+	case "go":
+		e.stop()
+		e.goCommand(fields[1:])
+	case "stop":
+		e.stop()
+	case "ponderhit":
+		if e.ponderhit != nil {
+			close(e.ponderhit)
+			e.ponderhit = nil
+		}
+	case "setoption":
+		e.setOption(fields[1:])
+	case "quit":
+		return false
+	case "d":
+		e.stop()
+		e.Board.DebugPrint()
+	default:
+		e.send("info string unknown command %s", fields[0])
+	}
+
+	return true
+}
+
+func (e *Engine) send(format string, args ...any) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	fmt.Fprintf(e.out, format+"\n", args...)
+}
+
+// Close stops any running search and waits for its bestmove
+func (e *Engine) Close() {
+	e.stop()
+}
+
+func (e *Engine) stop() {
+	if e.cancel != nil {
+		e.cancel()
+		e.cancel = nil
+	}
+	e.ponderhit = nil
+	e.wait()
+}
+
+// wait blocks until the running search (if any) has printed its bestmove
+func (e *Engine) wait() {
+	if e.done != nil {
+		<-e.done
+		e.done = nil
+	}
+}
+
+// --------------------
+// Commands
+// --------------------
+
+func (e *Engine) position(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("position: missing startpos or fen")
+	}
+
+	var movesAt int
+	switch args[0] {
+	case "startpos":
+		e.Board.FromFen(board.StartFen)
+		movesAt = 1
+	case "fen":
+		end := len(args)
+		for i, arg := range args {
+			if arg == "moves" {
+				end = i
+				break
+			}
+		}
+		if end == 1 {
+			return fmt.Errorf("position: missing fen")
+		}
+		if err := e.Board.FromFen(strings.Join(args[1:end], " ")); err != nil {
+			return err
+		}
+		movesAt = end
+	default:
+		return fmt.Errorf("position: unknown argument %s", args[0])
+	}
+
+	e.Board.Chess960 = e.Board.Chess960 || e.Chess960
+
+	if movesAt >= len(args) || args[movesAt] != "moves" {
+		return nil
+	}
+
+	for _, moveStr := range args[movesAt+1:] {
+		move, err := e.Board.ParseMove(moveStr)
+		if err != nil {
+			return err
+		}
+		e.Board.PlayMove(move)
+	}
+
+	return nil
+}
+
+// parseGo reads the arguments of go. unknown words and values that aren't
+// numbers are skipped
+func parseGo(args []string) (limits evaluation.Limits, infinite, ponder bool) {
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "infinite":
+			infinite = true
+			continue
+		case "ponder":
+			ponder = true
+			continue
+		}
+		if i+1 >= len(args) {
+			break
+		}
+		n, err := strconv.Atoi(args[i+1])
+		if err != nil {
+			continue
+		}
+		ms := time.Duration(n) * time.Millisecond
+
+		switch args[i] {
+		case "depth":
+			limits.Depth = n
+		case "nodes":
+			limits.Nodes = uint64(n)
+		case "mate":
+			limits.Mate = n
+		case "movetime":
+			limits.MoveTime = ms
+		case "wtime":
+			limits.Clock.WTime = ms
+		case "btime":
+			limits.Clock.BTime = ms
+		case "winc":
+			limits.Clock.WInc = ms
+		case "binc":
+			limits.Clock.BInc = ms
+		case "movestogo":
+			limits.Clock.MovesToGo = n
+		default:
+			continue
+		}
+		i++
+	}
+	return limits, infinite, ponder
+}
+
+func (e *Engine) goCommand(args []string) {
+	limits, infinite, ponder := parseGo(args)
+
+	// with nothing to stop on we fall back to the fixed Depth option
+	if !infinite && limits.Depth == 0 && limits.Nodes == 0 && limits.Mate == 0 &&
+		limits.MoveTime == 0 && !limits.Clock.IsSet() {
+		limits.Depth = e.Depth
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	e.cancel = cancel
+	e.done = done
+
+	var ponderhit chan struct{}
+	if ponder {
+		ponderhit = make(chan struct{})
+		limits.Ponder = ponderhit
+		e.ponderhit = ponderhit
+	}
+
+	go func() {
+		defer close(done)
+
+		if e.Board.Moves(false).Count == 0 {
+			e.send("bestmove 0000")
+			return
+		}
+
+		result := evaluation.SearchContext(ctx, &e.Board, limits, e.info)
+
+		// go infinite must not answer before the gui says stop, and a ponder
+		// search not before ponderhit or stop
+		switch {
+		case infinite:
+			<-ctx.Done()
+		case ponder:
+			select {
+			case <-ctx.Done():
+			case <-ponderhit:
+			}
+		}
+		ext := result.Extensions
+		e.send("info string extensions check %d singular %d multicut %d", ext.Check, ext.Singular, ext.MultiCut)
+
+		if reply := e.ponderMove(result); reply != 0 {
+			e.send("bestmove %s ponder %s", e.Board.MoveToUCI(result.Move), e.Board.MoveToUCI(reply))
+			return
+		}
+		e.send("bestmove %s", e.Board.MoveToUCI(result.Move))
+	}()
+}
+
+// ponderMove is the reply we expect to our move, taken from the pv or, when
+// the pv stops short, from the transposition table
+func (e *Engine) ponderMove(result evaluation.Result) moves.Move {
+	if len(result.PV) > 1 && result.PV[0] == result.Move {
+		return result.PV[1]
+	}
+
+	e.Board.PlayMove(result.Move)
+	defer e.Board.UndoMove(result.Move)
+
+	entry, ok := evaluation.ProbeTT(e.Board.Hash)
+	if !ok || entry.Move == 0 {
+		return 0
+	}
+	// a hash collision could hand us a move that is illegal here
+	legal := e.Board.Moves(false)
+	for i := 0; i < legal.Count; i++ {
+		if legal.Moves[i] == entry.Move {
+			return entry.Move
+		}
+	}
+	return 0
+}
+
+func (e *Engine) info(result evaluation.Result) {
+	nps := uint64(0)
+	if result.Time > 0 {
+		nps = uint64(float64(result.Nodes) / result.Time.Seconds())
+	}
+
+	pv := make([]string, len(result.PV))
+	for i, move := range result.PV {
+		pv[i] = e.Board.MoveToUCI(move)
+	}
+
+	e.send("info depth %d seldepth %d score %s nodes %d nps %d hashfull %d time %d pv %s",
+		result.Depth, result.SelDepth, FormatScore(result.Score), result.Nodes, nps,
+		evaluation.Hashfull(), result.Time.Milliseconds(), strings.Join(pv, " "))
+}
+
+func (e *Engine) setOption(args []string) {
+	// setoption name <id> [value <x>]
+	var name, value []string
+	var target *[]string
+	for _, arg := range args {
+		switch arg {
+		case "name":
+			target = &name
+		case "value":
+			target = &value
+		default:
+			if target != nil {
+				*target = append(*target, arg)
+			}
+		}
+	}
+
+	switch strings.ToLower(strings.Join(name, " ")) {
+	case "hash":
+		n, err := strconv.Atoi(strings.Join(value, " "))
+		if err != nil || n < 1 || n > evaluation.MaxHashMB {
+			e.send("info string invalid Hash value %s", strings.Join(value, " "))
+			return
+		}
+		e.stop()
+		evaluation.ResizeTT(n)
+	case "clear hash":
+		e.stop()
+		evaluation.ClearTT()
+	case "threads":
+		n, err := strconv.Atoi(strings.Join(value, " "))
+		if err != nil || n < 1 || n > evaluation.MaxThreads {
+			e.send("info string invalid Threads value %s", strings.Join(value, " "))
+			return
+		}
+		e.stop()
+		evaluation.SetThreads(n)
+	case "ponder":
+		// nothing to set, the gui decides when to send go ponder
+	case "depth":
+		n, err := strconv.Atoi(strings.Join(value, " "))
+		if err != nil || n < 1 || n > 64 {
+			e.send("info string invalid Depth value %s", strings.Join(value, " "))
+			return
+		}
+		e.Depth = n
+	case "uci_chess960":
+		e.Chess960 = strings.Join(value, " ") == "true"
+	default:
+		e.send("info string unknown option %s", strings.Join(name, " "))
+	}
+}
+
+// FormatScore writes a score as "cp x" or "mate n"
+func FormatScore(score int) string {
+	if n, ok := evaluation.MateIn(score); ok {
+		return fmt.Sprintf("mate %d", n)
+	}
+	return fmt.Sprintf("cp %d", score)
+}
@@ -0,0 +1,173 @@
+package uci
+
+import (
+	"bot/board"
+	"bot/evaluation"
+	"bytes"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	board.InitMagicBitboards()
+	board.InitZobrist()
+	os.Exit(m.Run())
+}
+
+// output collects what the engine sends, the search goroutine writes to it
+// while the test reads
+type output struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.Write(p)
+}
+
+func (o *output) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
+
+func TestParseGo(t *testing.T) {
+	for _, c := range []struct {
+		args             string
+		limits           evaluation.Limits
+		infinite, ponder bool
+	}{
+		{"", evaluation.Limits{}, false, false},
+		{"depth 5", evaluation.Limits{Depth: 5}, false, false},
+		{"nodes 10000 mate 3", evaluation.Limits{Nodes: 10000, Mate: 3}, false, false},
+		{"movetime 250", evaluation.Limits{MoveTime: 250 * time.Millisecond}, false, false},
+		{"infinite", evaluation.Limits{}, true, false},
+		{"wtime 60000 btime 50000 winc 1000 binc 2000 movestogo 20", evaluation.Limits{Clock: evaluation.Clock{
+			WTime: time.Minute, BTime: 50 * time.Second, WInc: time.Second, BInc: 2 * time.Second, MovesToGo: 20,
+		}}, false, false},
+		{"ponder wtime 1000 btime 1000", evaluation.Limits{Clock: evaluation.Clock{
+			WTime: time.Second, BTime: time.Second,
+		}}, false, true},
+
+		// junk and missing values are skipped without losing the rest
+		{"depth x movetime 100", evaluation.Limits{MoveTime: 100 * time.Millisecond}, false, false},
+		{"searchmoves e2e4 depth 3", evaluation.Limits{Depth: 3}, false, false},
+		{"depth", evaluation.Limits{}, false, false},
+	} {
+		limits, infinite, ponder := parseGo(strings.Fields(c.args))
+		if limits != c.limits || infinite != c.infinite || ponder != c.ponder {
+			t.Errorf("go %s: got %+v %v %v, want %+v %v %v", c.args, limits, infinite, ponder, c.limits, c.infinite, c.ponder)
+		}
+	}
+}
+
+func TestPosition(t *testing.T) {
+	for _, c := range []struct {
+		command, fen, err string
+	}{
+		{"position startpos", board.StartFen, ""},
+		{"position startpos moves e2e4 e7e5 g1f3",
+			"rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2", ""},
+		{"position fen 4k3/8/8/8/8/8/4P3/4K3 w - - 0 1", "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1", ""},
+		{"position fen 4k3/8/8/8/8/8/4P3/4K3 w - - 0 1 moves e2e4 e8d7",
+			"8/3k4/8/8/4P3/8/8/4K3 w - - 1 2", ""},
+		{"position fen 4k3/8/8/8/8/8/4P3/4K3 w - - moves e2e3", "4k3/8/8/8/8/4P3/8/4K3 b - - 0 1", ""},
+		{"position startpos moves e1g1 e2e4", board.StartFen, "illegal move"},
+		{"position fen", board.StartFen, "missing fen"},
+		{"position", board.StartFen, "missing startpos or fen"},
+		{"position somewhere", board.StartFen, "unknown argument"},
+		{"position fen 8/8/8/8/8/8/8/8 w - - 0 1", board.StartFen, "white has 0 kings"},
+	} {
+		var out output
+		e := NewEngine(&out)
+		e.Handle(c.command)
+
+		if got := e.Board.ToFen(); c.err == "" && got != c.fen {
+			t.Errorf("%s: board is %s, want %s", c.command, got, c.fen)
+		}
+		if got := out.String(); (c.err == "") != (got == "") || !strings.Contains(got, c.err) {
+			t.Errorf("%s: output %q, want an error containing %q", c.command, got, c.err)
+		}
+	}
+}
+
+func TestChess960Castling(t *testing.T) {
+	var out output
+	e := NewEngine(&out)
+
+	// a normal start still takes the usual castling notation
+	e.Handle("position fen r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1 moves e1g1")
+	if got := e.Board.ToFen(); got != "r3k2r/8/8/8/8/8/8/R4RK1 b kq - 1 1" {
+		t.Errorf("e1g1 gave %s", got)
+	}
+
+	// with UCI_Chess960 castling is king-takes-rook
+	e.Handle("setoption name UCI_Chess960 value true")
+	e.Handle("position fen r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1 moves e1h1")
+	if got := e.Board.ToFen(); got != "r3k2r/8/8/8/8/8/8/R4RK1 b kq - 1 1" || !e.Board.Chess960 {
+		t.Errorf("e1h1 gave %s", got)
+	}
+	if out.String() != "" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
+
+func TestGo(t *testing.T) {
+	bestmove := func(s string) string {
+		lines := strings.Split(strings.TrimSpace(s), "\n")
+		if last := lines[len(lines)-1]; strings.HasPrefix(last, "bestmove ") {
+			return strings.Fields(last)[1]
+		}
+		return ""
+	}
+
+	for _, c := range []struct {
+		commands []string
+		move     string // "" for any legal move
+	}{
+		{[]string{"position startpos", "go depth 3"}, ""},
+		{[]string{"position startpos moves e2e4", "go nodes 2000"}, ""},
+		{[]string{"position startpos", "go movetime 50"}, ""},
+		{[]string{"position fen 7k/8/6K1/8/8/8/8/R7 w - - 0 1", "go mate 1"}, "a1a8"},
+		{[]string{"position fen 7k/8/6K1/8/8/8/8/R7 w - - 0 1", "go wtime 1000 btime 1000"}, "a1a8"},
+		{[]string{"position fen 7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", "go depth 3"}, "0000"},
+	} {
+		var out output
+		e := NewEngine(&out)
+		for _, command := range c.commands {
+			e.Handle(command)
+		}
+		e.wait()
+
+		move := bestmove(out.String())
+		if c.move != "" && move != c.move {
+			t.Errorf("%v: bestmove %q, want %s\n%s", c.commands, move, c.move, out.String())
+		} else if c.move == "" {
+			if _, err := e.Board.ParseMove(move); err != nil {
+				t.Errorf("%v: bestmove %q is not legal\n%s", c.commands, move, out.String())
+			}
+		}
+	}
+}
+
+// go infinite keeps its bestmove until stop
+func TestGoInfinite(t *testing.T) {
+	var out output
+	e := NewEngine(&out)
+	e.Handle("position startpos")
+	e.Handle("go infinite")
+
+	time.Sleep(50 * time.Millisecond)
+	if strings.Contains(out.String(), "bestmove") {
+		t.Fatalf("bestmove before stop:\n%s", out.String())
+	}
+	e.Handle("stop")
+	if !strings.Contains(out.String(), "bestmove") {
+		t.Errorf("no bestmove after stop:\n%s", out.String())
+	}
+}
@@ -0,0 +1,323 @@
+package xboard
+
+import (
+	"bot/board"
+	"bot/evaluation"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Engine speaks the chess engine communication protocol (xboard/winboard)
+type Engine struct {
+	Board board.Board
+	Depth int // searched to when there is no time control and no sd
+
+	// depth limit from sd, it applies under time controls too
+	sdDepth int
+
+	// clocks in centiseconds as sent by time/otim
+	Time  int
+	OTime int
+
+	// time control from level/st
+	MovesPerSession int
+	Increment       time.Duration
+	MoveTime        time.Duration
+
+	force    bool
+	computer bool        // side the engine plays, true = white
+	post     atomic.Bool // read by the search goroutine
+
+	out     io.Writer
+	mu      sync.Mutex
+	done    chan struct{}
+	cancel  context.CancelFunc
+	discard atomic.Bool
+}
+
+func NewEngine(out io.Writer) *Engine {
+	e := &Engine{
+		Depth: 7,
+		out:   out,
+	}
+	e.newGame()
+	return e
+}
+
+// Handle runs a single command and returns false once the engine should exit
+func (e *Engine) Handle(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return true
+	}
+
+	switch fields[0] {
+	case "xboard", "accepted", "rejected", "random", "computer", "hard", "easy":
+	case "protover":
+		e.send("feature myname=\"%s\" ping=1 setboard=1 usermove=1 memory=1 smp=1 time=1 draw=0 sigint=0 sigterm=0 reuse=1 analyze=0 colors=0 done=1", evaluation.EngineName)
+	case "new":
+		e.abort()
+		e.newGame()
+	case "setboard":
+		e.abort()
+		if err := e.Board.FromFen(strings.Join(fields[1:], " ")); err != nil {
+			e.send("tellusererror Illegal position: %v", err)
+			return true
+		}
+	case "usermove":
+		e.abort()
+		if len(fields) < 2 {
+			e.send("Error (missing move): usermove")
+			return true
+		}
+		e.userMove(fields[1])
+	case "go":
+		e.abort()
+		e.force = false
+		e.computer = e.Board.Turn
+		e.think()
+	case "force":
+		e.abort()
+		e.force = true
+	case "time":
+		if len(fields) > 1 {
+			e.Time, _ = strconv.Atoi(fields[1])
+		}
+	case "otim":
+		if len(fields) > 1 {
+			e.OTime, _ = strconv.Atoi(fields[1])
+		}
+	case "level":
+		// level MPS BASE INC, base is minutes or minutes:seconds
+		if len(fields) > 3 {
+			e.MovesPerSession, _ = strconv.Atoi(fields[1])
+			inc, _ := strconv.ParseFloat(fields[3], 64)
+			e.Increment = time.Duration(inc * float64(time.Second))
+			e.MoveTime = 0
+		}
+	case "st":
+		if len(fields) > 1 {
+			if n, err := strconv.Atoi(fields[1]); err == nil && n > 0 {
+				e.MoveTime = time.Duration(n) * time.Second
+			}
+		}
+	case "memory":
+		if len(fields) > 1 {
+			if n, err := strconv.Atoi(fields[1]); err == nil && n > 0 {
+				e.abort()
+				evaluation.ResizeTT(n)
+			}
+		}
+	case "cores":
+		if len(fields) > 1 {
+			if n, err := strconv.Atoi(fields[1]); err == nil && n > 0 {
+				e.abort()
+				evaluation.SetThreads(n)
+			}
+		}
+	case "sd":
+		if len(fields) > 1 {
+			if n, err := strconv.Atoi(fields[1]); err == nil && n > 0 {
+				e.sdDepth = n
+			}
+		}
+	case "undo":
+		e.abort()
+		e.Board.TakeBack(1)
+	case "remove":
+		e.abort()
+		e.Board.TakeBack(2)
+	case "result":
+		e.abort()
+		e.force = true
+	case "?":
+		e.moveNow()
+	case "ping":
+		e.wait()
+		if len(fields) > 1 {
+			e.send("pong %s", fields[1])
+		}
+	case "post":
+		e.post.Store(true)
+	case "nopost":
+		e.post.Store(false)
+	case "quit":
+		return false
+	default:
+		// without usermove=1 some interfaces send bare moves
+		e.abort()
+		if _, err := e.Board.ParseMove(fields[0]); err == nil {
+			e.userMove(fields[0])
+			return true
+		}
+		e.send("Error (unknown command): %s", fields[0])
+	}
+
+	return true
+}
+
+// Close abandons any running search
+func (e *Engine) Close() {
+	e.abort()
+}
+
+func (e *Engine) send(format string, args ...any) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	fmt.Fprintf(e.out, format+"\n", args...)
+}
+
+func (e *Engine) wait() {
+	if e.done != nil {
+		<-e.done
+		e.done = nil
+	}
+}
+
+// moveNow cuts the search short, the engine still plays what it found
+func (e *Engine) moveNow() {
+	if e.cancel != nil {
+		e.cancel()
+		e.cancel = nil
+	}
+	e.wait()
+}
+
+// abort stops the search without playing its move
+func (e *Engine) abort() {
+	e.discard.Store(true)
+	e.moveNow()
+}
+
+// --------------------
+// Game Handling
+// --------------------
+
+func (e *Engine) newGame() {
+	e.Board.FromFen(board.StartFen)
+	e.force = false
+	e.computer = false
+	e.sdDepth = 0
+	evaluation.ClearTT()
+	evaluation.ClearHistory()
+}
+
+func (e *Engine) userMove(moveStr string) {
+	move, err := e.Board.ParseMove(moveStr)
+	if err != nil {
+		e.send("Illegal move: %s", moveStr)
+		return
+	}
+
+	e.Board.PlayMove(move)
+
+	if e.gameOver() {
+		return
+	}
+
+	if !e.force && e.Board.Turn == e.computer {
+		e.think()
+	}
+}
+
+func (e *Engine) think() {
+	if e.gameOver() {
+		return
+	}
+
+	limits := evaluation.Limits{
+		Depth:    e.sdDepth,
+		MoveTime: e.MoveTime,
+		Clock:    e.clock(),
+	}
+	if limits.Depth == 0 && limits.MoveTime == 0 && !limits.Clock.IsSet() {
+		limits.Depth = e.Depth
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	e.cancel = cancel
+	e.done = done
+	e.discard.Store(false)
+
+	go func() {
+		defer close(done)
+
+		result := evaluation.SearchContext(ctx, &e.Board, limits, e.thinking)
+		if e.discard.Load() {
+			return
+		}
+		move := result.Move
+
+		e.Board.PlayMove(move)
+		e.send("move %s", e.Board.MoveToUCI(move))
+
+		e.gameOver()
+	}()
+}
+
+// thinking prints the post output after every iteration
+func (e *Engine) thinking(result evaluation.Result) {
+	if !e.post.Load() {
+		return
+	}
+
+	pv := make([]string, len(result.PV))
+	for i, move := range result.PV {
+		pv[i] = e.Board.MoveToUCI(move)
+	}
+
+	// ply score time(centiseconds) nodes pv
+	e.send("%d %d %d %d %s", result.Depth, formatScore(result.Score),
+		result.Time.Milliseconds()/10, result.Nodes, strings.Join(pv, " "))
+}
+
+// clock converts the xboard time settings into the engine's side of a Clock
+func (e *Engine) clock() evaluation.Clock {
+	var clock evaluation.Clock
+	if e.Time <= 0 {
+		return clock
+	}
+
+	ours := time.Duration(e.Time) * 10 * time.Millisecond
+	theirs := time.Duration(e.OTime) * 10 * time.Millisecond
+	clock.WTime, clock.BTime = theirs, ours
+	if e.computer {
+		clock.WTime, clock.BTime = ours, theirs
+	}
+	clock.WInc, clock.BInc = e.Increment, e.Increment
+
+	if e.MovesPerSession > 0 {
+		played := (e.Board.UndoCount + 1) / 2
+		clock.MovesToGo = e.MovesPerSession - played%e.MovesPerSession
+	}
+	return clock
+}
+
+// gameOver reports the result when the side to move has no legal moves or
+// the game is drawn by rule
+func (e *Engine) gameOver() bool {
+	result, reason := e.Board.Outcome()
+	if result == "" {
+		return false
+	}
+	e.send("%s {%s}", result, reason)
+	return true
+}
+
+// formatScore uses the usual xboard convention of 100000+n for a mate in n
+func formatScore(score int) int {
+	if n, ok := evaluation.MateIn(score); ok {
+		if n > 0 {
+			return 100000 + n
+		}
+		return -100000 + n
+	}
+	return score
+}
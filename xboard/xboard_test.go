@@ -0,0 +1,62 @@
+package xboard
+
+import (
+	"bot/board"
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	board.InitMagicBitboards()
+	board.InitZobrist()
+	os.Exit(m.Run())
+}
+
+// run feeds the commands to a new engine and returns the lines it sent back,
+// the trailing ping waits for any search to finish
+func run(t *testing.T, commands ...string) []string {
+	t.Helper()
+	var out bytes.Buffer
+	e := NewEngine(&out)
+	for _, command := range append(commands, "ping 1") {
+		e.Handle(command)
+	}
+	e.Close()
+	return strings.Split(strings.TrimSpace(out.String()), "\n")
+}
+
+// sd limits the depth under a time control as well
+func TestSearchDepth(t *testing.T) {
+	for _, c := range []struct {
+		commands []string
+		depth    string
+	}{
+		{[]string{"sd 3"}, "3"},
+		{[]string{"sd 3", "time 30000", "otim 30000"}, "3"},
+		{[]string{"sd 2", "st 30"}, "2"},
+		{[]string{"sd 2", "new", "sd 3", "level 40 5 0"}, "3"},
+	} {
+		commands := append([]string{"new", "post"}, c.commands...)
+		lines := run(t, append(commands, "usermove e2e4")...)
+
+		last := ""
+		for _, line := range lines {
+			if fields := strings.Fields(line); len(fields) > 4 && fields[0] != "move" {
+				last = fields[0]
+			}
+		}
+		if last != c.depth {
+			t.Errorf("%v: searched to depth %s, want %s\n%s", c.commands, last, c.depth, strings.Join(lines, "\n"))
+		}
+	}
+}
+
+// post and nopost can arrive while the engine is thinking, run with -race
+func TestPostWhileThinking(t *testing.T) {
+	lines := run(t, "new", "st 30", "usermove e2e4", "post", "nopost", "post", "?")
+	if !strings.HasPrefix(lines[len(lines)-2], "move ") {
+		t.Errorf("no move after ?:\n%s", strings.Join(lines, "\n"))
+	}
+}